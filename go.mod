@@ -1,6 +1,6 @@
 module github.com/foxxorcat/library-go
 
-go 1.20
+go 1.21
 
 require (
 	github.com/hashicorp/golang-lru/v2 v2.0.2
@@ -0,0 +1,145 @@
+package ioutils
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Adapt 选择的适配方式
+type AdaptStrategy int
+
+const (
+	AdaptNative       AdaptStrategy = iota // 已实现全部接口
+	AdaptAddSize                           // 补充 Size 与 Close
+	AdaptSection                           // io.ReaderAt 经 SectionReaderAt 支持 Read & Seek
+	AdaptSeeker                            // io.ReadSeeker 经 ReaderAtFromSeeker 支持 ReadAt
+	AdaptSeekerBuffer                      // io.ReadSeeker 经 NewBufferReadSeeker 支持 ReadAt
+	AdaptReaderBuffer                      // io.Reader 经 NewBufferReader 缓存
+)
+
+func (s AdaptStrategy) String() string {
+	switch s {
+	case AdaptNative:
+		return "native"
+	case AdaptAddSize:
+		return "add-size"
+	case AdaptSection:
+		return "section"
+	case AdaptSeeker:
+		return "seeker"
+	case AdaptSeekerBuffer:
+		return "seeker-buffer"
+	case AdaptReaderBuffer:
+		return "reader-buffer"
+	}
+	return "unknown"
+}
+
+type AdaptOption func(*AdaptOptions)
+
+type AdaptOptions struct {
+	Size       int64                // 已知大小，<0 表示自动获取
+	BlockSize  int                  // NewBufferReadSeeker 缓存块大小，<=0 表示不缓存
+	BlockNum   int                  // NewBufferReadSeeker 缓存块数量
+	Unbuffered bool                 // io.ReadSeeker 经 ReaderAtFromSeeker 串行访问，不缓存
+	Buffer     []BufferReaderOption // NewBufferReader 选项
+}
+
+// SetAdaptSize
+// 指定数据大小，不再自动获取
+func SetAdaptSize(size int64) AdaptOption {
+	return func(o *AdaptOptions) {
+		o.Size = size
+	}
+}
+
+// SetAdaptBlock
+// 设置 io.ReadSeeker 的缓存块
+func SetAdaptBlock(blockSize, blockNum int) AdaptOption {
+	return func(o *AdaptOptions) {
+		o.BlockSize = blockSize
+		o.BlockNum = blockNum
+	}
+}
+
+// SetAdaptUnbuffered
+// io.ReadSeeker 不经缓存，由 ReaderAtFromSeeker 串行访问
+// 适用于顺序读取或r自身已有缓存的情况
+func SetAdaptUnbuffered(unbuffered bool) AdaptOption {
+	return func(o *AdaptOptions) {
+		o.Unbuffered = unbuffered
+	}
+}
+
+// SetAdaptBuffer
+// 设置 io.Reader 的缓存选项
+func SetAdaptBuffer(opts ...BufferReaderOption) AdaptOption {
+	return func(o *AdaptOptions) {
+		o.Buffer = opts
+	}
+}
+
+// Adapt
+// 检查r实现的接口，组合开销最小的适配转换为 SizeReadSeekReadAtCloser
+// 返回值同时报告选择的适配方式
+// 1. 已实现全部接口且大小已知时直接返回
+// 2. io.ReadSeeker & io.ReaderAt 补充 Size 与 Close
+// 3. io.ReaderAt 经 SectionReaderAt 访问
+// 4. io.ReadSeeker 经 NewBufferReadSeeker 缓存，设置 SetAdaptUnbuffered 时经 ReaderAtFromSeeker 串行访问
+// 5. io.Reader 或无法获取大小时经 NewBufferReader 缓存，大小未知时会读取全部数据
+// 返回值的 Size() 总是有效，只实现 io.ReaderAt 且无法获取大小时返回错误
+// Close 时若r实现了 io.Closer 则一并关闭，io.Reader 读取失败时r同样会被关闭
+func Adapt(r any, opts ...AdaptOption) (SizeReadSeekReadAtCloser, AdaptStrategy, error) {
+	options := AdaptOptions{Size: -1, BlockSize: 64 * 1024, BlockNum: 16}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if v, ok := r.(SizeReadSeekReadAtCloser); ok && options.Size < 0 && v.Size() >= 0 {
+		return v, AdaptNative, nil
+	}
+
+	size := options.Size
+	if size < 0 {
+		if n, err := GetStreamSize(r); err == nil && n >= 0 {
+			size = n
+		}
+	}
+	c, _ := r.(io.Closer)
+
+	if size >= 0 {
+		switch v := r.(type) {
+		case ReadSeekReaderAt:
+			return &readSeekReaderAtAddSizeCloser{ReadSeekReaderAt: v, size: size, close: c}, AdaptAddSize, nil
+		case ReaderAt:
+			return &readSeekReaderAtAddSizeCloser{ReadSeekReaderAt: SectionReaderAt(v, 0, size), size: size, close: c}, AdaptSection, nil
+		case ReadSeeker:
+			if options.Unbuffered || options.BlockSize <= 0 {
+				return &readSeekReaderAtAddSizeCloser{ReadSeekReaderAt: ReaderAtFromSeeker(v, true), size: size, close: c}, AdaptSeeker, nil
+			}
+			br := NewBufferReadSeeker(v, options.BlockSize, options.BlockNum)
+			return &readSeekReaderAtAddSizeCloser{ReadSeekReaderAt: br, size: size, close: br}, AdaptSeekerBuffer, nil
+		}
+	}
+
+	// 无法获取大小时（如管道）只能顺序读取并缓存
+	switch v := r.(type) {
+	case Reader:
+		br := NewBufferReader(v, options.Buffer...)
+		if size < 0 {
+			var err error
+			if size, err = br.Seek(0, io.SeekEnd); err == nil {
+				_, err = br.Seek(0, io.SeekStart)
+			}
+			if err != nil {
+				br.Close()
+				return nil, 0, err
+			}
+		}
+		return &readSeekReaderAtAddSizeCloser{ReadSeekReaderAt: br, size: size, close: br}, AdaptReaderBuffer, nil
+	case ReaderAt:
+		return nil, 0, errors.Errorf("unable to get size of %T", r)
+	}
+	return nil, 0, errors.Errorf("%T is not a reader", r)
+}
@@ -0,0 +1,200 @@
+package ioutils
+
+import (
+	"io"
+	"sync"
+
+	"github.com/foxxorcat/library-go/pool"
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// NewBlockCache
+// 可由多个 io.ReaderAt 共享的块缓存，按总字节数限制内存占用
+// 超出预算时，从占用块最多的读取器中淘汰最久未使用的块
+// @param blockSize 缓存块大小
+// @param maxBytes 缓存总字节数上限
+func NewBlockCache(blockSize int, maxBytes int64) *BlockCache {
+	maxBlocks := int(maxBytes / int64(blockSize))
+	if maxBlocks < 1 {
+		maxBlocks = 1
+	}
+
+	return &BlockCache{
+		blockSize: blockSize,
+		maxBlocks: maxBlocks,
+		pool: pool.NewPoolCap(maxBlocks, func() []byte {
+			return make([]byte, blockSize)
+		}),
+		readers: make(map[uint64]*simplelru.LRU[int, []byte]),
+	}
+}
+
+type BlockCache struct {
+	blockSize int // 缓存块大小
+	maxBlocks int // 缓存块数量上限
+	pool      *pool.PoolChan[[]byte]
+
+	lock    sync.Mutex
+	nextID  uint64
+	blocks  int                                    // 已缓存块数量
+	readers map[uint64]*simplelru.LRU[int, []byte] // 读取器id -> 块缓存
+}
+
+// NewReaderAt
+// 将 io.ReaderAt 接入共享缓存
+// 关闭返回的读取器时释放其占用的缓存块
+func (c *BlockCache) NewReaderAt(r io.ReaderAt) *blockCacheReaderAt {
+	// 获取大小可能较慢，不能持有共享的锁
+	size, err := GetStreamSize(r, SetNoSideEffect(true))
+	if err != nil {
+		size = -1
+	}
+
+	blocks, err := simplelru.NewLRU(c.maxBlocks, func(key int, value []byte) {
+		c.blocks--
+		c.pool.Put(value)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	c.lock.Lock()
+	c.nextID++
+	id := c.nextID
+	c.readers[id] = blocks
+	c.lock.Unlock()
+
+	br := &blockCacheReaderAt{
+		r:     r,
+		size:  size,
+		id:    id,
+		cache: c,
+	}
+	if cl, ok := r.(io.Closer); ok {
+		br.c = cl
+	}
+	return br
+}
+
+// Len 已缓存块数量
+func (c *BlockCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.blocks
+}
+
+// Cap 可缓存块数量
+func (c *BlockCache) Cap() int {
+	return c.maxBlocks
+}
+
+// 从缓存块复制数据到p
+func (c *BlockCache) read(id uint64, index, offset int, p []byte) (n int, ok bool, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if blocks, ok := c.readers[id]; ok {
+		if buf, ok := blocks.Get(index); ok {
+			n, err = copyBlock(buf, offset, p)
+			return n, true, err
+		}
+	}
+	return 0, false, nil
+}
+
+// 添加块到缓存并复制数据到p，超出预算时淘汰
+func (c *BlockCache) add(id uint64, index int, buf []byte, offset int, p []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	blocks, ok := c.readers[id]
+	if !ok {
+		// 读取器已关闭
+		defer c.pool.Put(buf)
+		return copyBlock(buf, offset, p)
+	}
+
+	// 其他协程已加载该块
+	if old, ok := blocks.Get(index); ok {
+		c.pool.Put(buf)
+		return copyBlock(old, offset, p)
+	}
+
+	for c.blocks >= c.maxBlocks && c.evict() {
+	}
+	blocks.Add(index, buf)
+	c.blocks++
+	return copyBlock(buf, offset, p)
+}
+
+// 从占用块最多的读取器中淘汰最久未使用的块
+func (c *BlockCache) evict() bool {
+	var victim *simplelru.LRU[int, []byte]
+	for _, blocks := range c.readers {
+		if victim == nil || blocks.Len() > victim.Len() {
+			victim = blocks
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	_, _, ok := victim.RemoveOldest()
+	return ok
+}
+
+// 移除读取器及其缓存块
+func (c *BlockCache) remove(id uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if blocks, ok := c.readers[id]; ok {
+		blocks.Purge()
+		delete(c.readers, id)
+	}
+}
+
+type blockCacheReaderAt struct {
+	r     io.ReaderAt
+	c     io.Closer
+	size  int64 // 数据大小，-1 表示未知
+	id    uint64
+	cache *BlockCache
+}
+
+// 读取块，未缓存时加载到缓存
+// 加载时不持有锁，不阻塞其他读取
+func (r *blockCacheReaderAt) readBlock(index, offset int, p []byte) (int, error) {
+	if n, ok, err := r.cache.read(r.id, index, offset, p); ok {
+		return n, err
+	}
+
+	buf := r.cache.pool.Get()
+	n, err := r.r.ReadAt(buf[:blockLen(index, r.cache.blockSize, r.size)], int64(index)*int64(r.cache.blockSize))
+	if err != nil && err != io.EOF {
+		r.cache.pool.Put(buf)
+		return 0, err
+	}
+	return r.cache.add(r.id, index, buf[:n], offset, p)
+}
+
+func (r *blockCacheReaderAt) ReadAt(p []byte, off int64) (rn int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+	return readAtBlocks(p, off, r.size, r.cache.blockSize, r.readBlock)
+}
+
+// Size 数据大小，-1 表示未知
+func (r *blockCacheReaderAt) Size() int64 {
+	return r.size
+}
+
+func (r *blockCacheReaderAt) Close() error {
+	r.cache.remove(r.id)
+	if r.c != nil {
+		return r.c.Close()
+	}
+	return nil
+}
+
+var _ SizeReaderAtCloser = (*blockCacheReaderAt)(nil)
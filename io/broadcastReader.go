@@ -0,0 +1,92 @@
+package ioutils
+
+import (
+	"io"
+	"sync"
+)
+
+// Broadcast
+// 将只能读取一次的 r 共享给多个读取速度不同的读取者
+// 仅缓存最慢与最快读取者之间的数据，所有读取者都已读过的部分随即释放
+// 读取者应在开始读取前创建，之后创建的读取者从最慢读取者的位置开始
+func Broadcast(r io.Reader) *broadcaster {
+	return &broadcaster{
+		br:      NewBufferReader(r),
+		readers: make(map[*broadcastReader]struct{}),
+	}
+}
+
+type broadcaster struct {
+	br *bufferReader
+
+	lock     sync.Mutex
+	readers  map[*broadcastReader]struct{}
+	released int64 // 已释放位置
+}
+
+// NewReader 创建独立的读取者
+func (b *broadcaster) NewReader() *broadcastReader {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	c := &broadcastReader{b: b, off: b.released}
+	if off, ok := b.min(); ok {
+		c.off = off
+	}
+	b.readers[c] = struct{}{}
+	return c
+}
+
+// 最慢读取者的位置
+func (b *broadcaster) min() (off int64, ok bool) {
+	for c := range b.readers {
+		if !ok || c.off < off {
+			off, ok = c.off, true
+		}
+	}
+	return
+}
+
+// 释放所有读取者都已读过的数据
+// 调用时需持有锁
+func (b *broadcaster) release() {
+	if off, ok := b.min(); ok && off > b.released {
+		b.released = off
+		b.br.lock.Lock()
+		b.br.release(off)
+		b.br.lock.Unlock()
+	}
+}
+
+// Close
+// 释放缓存，若r实现了 io.Closer 则一并关闭
+func (b *broadcaster) Close() error {
+	return b.br.Close()
+}
+
+type broadcastReader struct {
+	b   *broadcaster
+	off int64
+}
+
+func (c *broadcastReader) Read(p []byte) (n int, err error) {
+	n, err = c.b.br.readAvailable(p, c.off)
+
+	c.b.lock.Lock()
+	c.off += int64(n)
+	c.b.release()
+	c.b.lock.Unlock()
+	return
+}
+
+// Close 停止读取，不再阻止数据释放
+func (c *broadcastReader) Close() error {
+	c.b.lock.Lock()
+	defer c.b.lock.Unlock()
+
+	delete(c.b.readers, c)
+	c.b.release()
+	return nil
+}
+
+var _ io.ReadCloser = (*broadcastReader)(nil)
@@ -13,6 +13,7 @@ import (
 // io.ErrUnexpectedEOF 转换为 io.EOF
 // @param blockSize 缓存块大小。
 // @param blockNum 缓存块数量.
+// 读取位置由自身维护，与r的位置无关
 func NewBufferReadSeeker(r io.ReadSeeker, blockSize int, blockNum int) *bufferReadSeeker {
 	pool := pool.NewPoolCap(blockNum, func() []byte {
 		return make([]byte, blockSize)
@@ -24,8 +25,15 @@ func NewBufferReadSeeker(r io.ReadSeeker, blockSize int, blockNum int) *bufferRe
 		panic(err)
 	}
 
+	// 缓存大小，之后仅在 readBlock 中访问r
+	size, err := GetStreamSize(r)
+	if err != nil {
+		size = -1
+	}
+
 	br := &bufferReadSeeker{
 		r:           r,
+		size:        size,
 		pool:        pool,
 		blockSize:   blockSize,
 		cacheBlocks: cache,
@@ -38,9 +46,10 @@ func NewBufferReadSeeker(r io.ReadSeeker, blockSize int, blockNum int) *bufferRe
 }
 
 type bufferReadSeeker struct {
-	r   io.ReadSeeker
-	c   io.Closer
-	off int64
+	r    io.ReadSeeker
+	c    io.Closer
+	off  int64
+	size int64 // 数据大小，-1 表示未知
 
 	lock sync.Mutex
 
@@ -55,67 +64,51 @@ func (r *bufferReadSeeker) Read(p []byte) (n int, err error) {
 	return
 }
 
-func (r *bufferReadSeeker) Seek(offset int64, whence int) (n int64, err error) {
-	n, err = r.r.Seek(offset, whence)
-	r.off = n
-	return
+func (r *bufferReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	off, err := resolveSeek(offset, whence, r.off, r.size)
+	if err != nil {
+		return r.off, err
+	}
+	r.off = off
+	return r.off, nil
 }
 
-// 加载块到缓存
-func (r *bufferReadSeeker) loadBlock(index int) ([]byte, error) {
+// 读取块，未缓存时加载到缓存
+func (r *bufferReadSeeker) readBlock(index, offset int, p []byte) (int, error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
 	if buf, ok := r.cacheBlocks.Get(index); ok {
-		return buf, nil
+		return copyBlock(buf, offset, p)
 	}
 
 	buf := r.pool.Get()
 	_, err := r.r.Seek(int64(index)*int64(r.blockSize), io.SeekStart)
 	if err != nil {
-		return nil, err
+		r.pool.Put(buf)
+		return 0, err
 	}
 
-	n, err := io.ReadFull(r.r, buf[:r.blockSize])
-	if err != nil && err != io.EOF {
-		return nil, err
+	n, err := io.ReadFull(r.r, buf[:blockLen(index, r.blockSize, r.size)])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		r.pool.Put(buf)
+		return 0, err
 	}
 	buf = buf[:n]
 	r.cacheBlocks.Add(index, buf)
-	return buf, nil
+	return copyBlock(buf, offset, p)
 }
 
 func (r *bufferReadSeeker) ReadAt(p []byte, off int64) (rn int, err error) {
 	if off < 0 {
 		return 0, ErrNegativeOffset
 	}
+	return readAtBlocks(p, off, r.size, r.blockSize, r.readBlock)
+}
 
-	index := int(off / int64(r.blockSize))  // 缓存块编号
-	offset := int(off % int64(r.blockSize)) //  缓存块偏移
-	for len(p) > 0 {
-		block, err := r.loadBlock(index)
-		if err != nil {
-			return rn, err
-		}
-
-		// 读取范围超过 block（仅在读取末端时触发）
-		if offset >= len(block) {
-			return rn, io.EOF
-		}
-
-		// 读取
-		n := copy(p, block[offset:])
-		p = p[n:]
-		rn += n
-		offset += n
-
-		// 读取下一个块
-		if offset >= r.blockSize {
-			index++
-			offset = 0
-		}
-	}
-	return rn, nil
+// Size 数据大小，-1 表示未知
+func (r *bufferReadSeeker) Size() int64 {
+	return r.size
 }
 
 func (r *bufferReadSeeker) Close() error {
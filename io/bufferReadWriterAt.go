@@ -0,0 +1,214 @@
+package ioutils
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/foxxorcat/library-go/pool"
+	systemutil "github.com/foxxorcat/library-go/system"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// NewReadWriterAtBuffer
+// 基于lru为 io.ReaderAt & io.WriterAt 提供写回缓存
+// 写入先合并到缓存块，在块被淘汰、Flush 或 Close 时写入底层
+// @param blockSize 缓存块大小
+// @param blockNum 缓存块数量
+func NewReadWriterAtBuffer(rw ReadWriterAt, blockSize int, blockNum int) *readWriterAtBuffer {
+	pool := pool.NewPoolCap(blockNum, func() []byte {
+		return make([]byte, blockSize)
+	})
+
+	size, err := GetStreamSize(rw, SetNoSideEffect(true))
+	b := &readWriterAtBuffer{
+		rw:        rw,
+		size:      systemutil.Max(size, 0),
+		sized:     err == nil,
+		pool:      pool,
+		blockSize: blockSize,
+	}
+	cache, err := lru.NewWithEvict(blockNum, func(key int, value *writeBlock) {
+		// 淘汰时写回，错误留待 Flush/Close 返回
+		if value.dirty {
+			if err := b.writeBlock(key, value); err != nil && b.err == nil {
+				b.err = err
+			}
+		}
+		pool.Put(value.buf)
+	})
+	if err != nil {
+		panic(err)
+	}
+	b.cacheBlocks = cache
+
+	if c, ok := rw.(io.Closer); ok {
+		b.c = c
+	}
+	return b
+}
+
+type writeBlock struct {
+	buf   []byte
+	dirty bool // 是否需要写回
+}
+
+type readWriterAtBuffer struct {
+	rw   ReadWriterAt
+	c    io.Closer
+	lock sync.Mutex
+
+	size  int64 // 数据大小，底层大小未知时为已写入的最大偏移，仅用于补零
+	sized bool  // 底层大小是否已知，未知时 Size() 返回 -1
+	err   error // 淘汰写回时的错误
+
+	pool        *pool.PoolChan[[]byte]
+	blockSize   int                          // 缓存块大小
+	cacheBlocks *lru.Cache[int, *writeBlock] // 块缓存
+}
+
+// 加载块到缓存
+// 块末端位于已写入范围内时补零
+func (b *readWriterAtBuffer) loadBlock(index int) (*writeBlock, error) {
+	blk, ok := b.cacheBlocks.Get(index)
+	if !ok {
+		buf := b.pool.Get()
+		n, err := b.rw.ReadAt(buf[:b.blockSize], int64(index)*int64(b.blockSize))
+		if err != nil && err != io.EOF {
+			b.pool.Put(buf)
+			return nil, err
+		}
+		blk = &writeBlock{buf: buf[:n]}
+		b.cacheBlocks.Add(index, blk)
+	}
+
+	if end := b.size - int64(index)*int64(b.blockSize); end > int64(len(blk.buf)) {
+		blk.grow(int(systemutil.Min(end, b.blockSize)))
+	}
+	return blk, nil
+}
+
+// 扩展块长度，新增部分补零
+func (blk *writeBlock) grow(n int) {
+	if n > len(blk.buf) {
+		l := len(blk.buf)
+		blk.buf = blk.buf[:n]
+		clear(blk.buf[l:])
+	}
+}
+
+func (b *readWriterAtBuffer) writeBlock(index int, blk *writeBlock) error {
+	_, err := b.rw.WriteAt(blk.buf, int64(index)*int64(b.blockSize))
+	return err
+}
+
+func (b *readWriterAtBuffer) ReadAt(p []byte, off int64) (rn int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return readAtBlocks(p, off, b.knownSize(), b.blockSize, func(index, offset int, p []byte) (int, error) {
+		blk, err := b.loadBlock(index)
+		if err != nil {
+			return 0, err
+		}
+		return copyBlock(blk.buf, offset, p)
+	})
+}
+
+func (b *readWriterAtBuffer) WriteAt(p []byte, off int64) (wn int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	index := int(off / int64(b.blockSize))  // 缓存块编号
+	offset := int(off % int64(b.blockSize)) //  缓存块偏移
+	for len(p) > 0 {
+		var blk *writeBlock
+		if offset == 0 && len(p) >= b.blockSize {
+			// 覆盖整块，无需读取
+			if blk, _ = b.cacheBlocks.Get(index); blk == nil {
+				blk = &writeBlock{buf: b.pool.Get()[:0]}
+				b.cacheBlocks.Add(index, blk)
+			}
+		} else if blk, err = b.loadBlock(index); err != nil {
+			return wn, err
+		}
+
+		n := systemutil.Min(len(p), b.blockSize-offset)
+		blk.grow(offset + n)
+		copy(blk.buf[offset:], p[:n])
+		blk.dirty = true
+
+		p = p[n:]
+		wn += n
+		if end := int64(index)*int64(b.blockSize) + int64(offset+n); end > b.size {
+			b.size = end
+		}
+
+		// 写入下一个块
+		index++
+		offset = 0
+	}
+	return wn, nil
+}
+
+// Size 数据大小，-1 表示未知
+func (b *readWriterAtBuffer) Size() int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.knownSize()
+}
+
+func (b *readWriterAtBuffer) knownSize() int64 {
+	if !b.sized {
+		return -1
+	}
+	return b.size
+}
+
+// Flush
+// 将所有脏块写回底层
+func (b *readWriterAtBuffer) Flush() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// 按偏移顺序写回
+	keys := b.cacheBlocks.Keys()
+	sort.Ints(keys)
+	for _, key := range keys {
+		blk, ok := b.cacheBlocks.Peek(key)
+		if !ok || !blk.dirty {
+			continue
+		}
+		if err := b.writeBlock(key, blk); err != nil {
+			return err
+		}
+		blk.dirty = false
+	}
+
+	err := b.err
+	b.err = nil
+	return err
+}
+
+func (b *readWriterAtBuffer) Close() error {
+	err := b.Flush()
+	if b.c != nil {
+		err = errors.Join(err, b.c.Close())
+	}
+	return err
+}
+
+var _ ReadWriterAt = (*readWriterAtBuffer)(nil)
+var _ SizeReaderAt = (*readWriterAtBuffer)(nil)
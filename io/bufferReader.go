@@ -3,56 +3,180 @@ package ioutils
 import (
 	"errors"
 	"io"
+	"math"
+	"os"
 	"sync"
+
+	"github.com/foxxorcat/library-go/pool"
+	systemutil "github.com/foxxorcat/library-go/system"
 )
 
+type BufferReaderOption func(*BufferReaderOptions)
+
+type BufferReaderOptions struct {
+	MemSize  int64  // 内存中保留的大小，超出部分写入临时文件，<=0 表示全部保存在内存
+	SpillDir string // 临时文件目录，为空时使用 os.TempDir
+	Window   int64  // 仅保留最近读取的大小，<=0 表示保留全部
+}
+
+// SetSpill
+// 仅在内存中保留前 memSize 字节，其余写入 dir 下的临时文件
+// 临时文件在 Close 时删除
+func SetSpill(memSize int64, dir string) BufferReaderOption {
+	return func(o *BufferReaderOptions) {
+		o.MemSize = memSize
+		o.SpillDir = dir
+	}
+}
+
+// SetWindow
+// 仅保留最近读取的 size 字节，读取窗口之前的数据返回 ErrOutsideWindow
+// 内存占用不超过 size 与单次读取大小之和
+// 与 SetSpill 同时使用时仅限制可读范围，不释放临时文件
+func SetWindow(size int64) BufferReaderOption {
+	return func(o *BufferReaderOptions) {
+		o.Window = size
+	}
+}
+
 // NewBufferingReaderAt
 // 将 io.Reader 读取到内存中以支持 io.ReaderAt & io.ReadSeeker
-// 按需求读取到内存
-func NewBufferReader(r io.Reader) *bufferReader {
-	return &bufferReader{r: r}
+// 按需求由后台协程读取到内存，已缓存部分的读取无需等待
+func NewBufferReader(r io.Reader, opts ...BufferReaderOption) *bufferReader {
+	var options BufferReaderOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	br := &bufferReader{r: r, window: options.Window}
+	br.cond = sync.NewCond(&br.lock)
+	if options.MemSize > 0 {
+		br.store = &spillStore{limit: options.MemSize, dir: options.SpillDir}
+	} else {
+		br.store = &memStore{}
+	}
+	return br
 }
 
 type bufferReader struct {
-	r    io.Reader
-	buf  []byte
-	lock sync.RWMutex
+	r     io.Reader
+	store bufferStore
+
+	lock    sync.RWMutex // 读取已缓存的数据只需读锁
+	cond    *sync.Cond   // 数据增加或读取结束时广播，使用写锁
+	want    int64        // 需要读取到的位置
+	filling bool         // 后台读取是否运行
+	err     error        // 读取r的错误，包括 io.EOF
+	closed  bool
 
 	offset int64
-	eof    bool
+
+	window int64 // 窗口大小
+	start  int64 // 窗口起始位置
 }
 
-// 读取指定长度的块到缓冲区
-// 如果读取完毕，标记为eof并返回io.EOF
-// io.ErrUnexpectedEOF 转化为 io.EOF
-func (br *bufferReader) readToBuf(l int64) error {
-	if !br.eof {
-		buf := make([]byte, l)
-		rn, err := io.ReadFull(br.r, buf)
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			br.eof = true
-			err = io.EOF
+// 后台读取r，直到满足 want 或读取结束
+// 写入临时文件时不持有锁，仅在提交数据时持有锁
+func (br *bufferReader) fill() {
+	buf := bufferChunkPool.Get()
+	defer bufferChunkPool.Put(buf)
+
+	br.lock.Lock()
+	defer br.lock.Unlock()
+	for !br.closed && br.err == nil && br.store.Len() < br.want {
+		br.lock.Unlock()
+		n, err := br.r.Read(buf)
+		if n > 0 {
+			if serr := br.store.Stage(buf[:n]); serr != nil {
+				n, err = 0, serr
+			}
 		}
-		br.buf = append(br.buf, buf[:rn]...)
-		return err
+		br.lock.Lock()
+
+		if br.closed {
+			break
+		}
+		if n > 0 {
+			br.store.Commit(buf[:n])
+		}
+		if err != nil {
+			br.err = err
+		}
+		br.cond.Broadcast()
+	}
+	br.filling = false
+}
+
+// 等待数据读取到 end，调用时需持有写锁
+// 数据不足时返回读取错误
+func (br *bufferReader) waitFor(end int64) error {
+	for br.store.Len() < end {
+		if br.closed {
+			return os.ErrClosed
+		}
+		if br.err != nil {
+			return br.err
+		}
+
+		if end > br.want {
+			br.want = end
+		}
+		if !br.filling {
+			br.filling = true
+			go br.fill()
+		}
+		br.cond.Wait()
+	}
+	return nil
+}
+
+// 等待数据读取到 end，调用时需持有写锁
+// 窗口模式下按分块大小分步读取，每步释放窗口之前的数据，keep 之后的数据保证不被释放
+func (br *bufferReader) fillTo(end, keep int64) error {
+	if br.window <= 0 {
+		return br.waitFor(end)
 	}
-	return io.EOF
+	for br.store.Len() < end {
+		if err := br.waitFor(systemutil.Min(end, br.store.Len()+bufferChunkSize)); err != nil {
+			return err
+		}
+		br.trim(keep)
+	}
+	return nil
 }
 
 // 将剩余部分全部读取到缓存
 // 仅返回非 io.EOF 错误
 func (br *bufferReader) readAll() error {
-	if !br.eof {
-		buf, err := io.ReadAll(br.r)
-		if err != nil {
-			return err
-		}
-		br.eof = true
-		br.buf = append(br.buf, buf...)
+	if err := br.fillTo(math.MaxInt64, math.MaxInt64); err != io.EOF {
+		return err
 	}
 	return nil
 }
 
+// 窗口模式下释放窗口之前的数据
+// keep 之后的数据保证不被释放
+func (br *bufferReader) trim(keep int64) {
+	if br.window <= 0 {
+		return
+	}
+
+	start := br.store.Len() - br.window
+	if start > keep {
+		start = keep
+	}
+	br.release(start)
+}
+
+// 释放 off 之前的数据，之后的读取返回 ErrOutsideWindow
+// 调用时需持有锁
+func (br *bufferReader) release(off int64) {
+	if off > br.start {
+		br.start = off
+		br.store.Release(off)
+	}
+}
+
 func (br *bufferReader) Read(p []byte) (n int, err error) {
 	n, err = br.ReadAt(p, br.offset)
 	br.offset += int64(n)
@@ -63,25 +187,26 @@ func (br *bufferReader) Seek(offset int64, whence int) (int64, error) {
 	br.lock.Lock()
 	defer br.lock.Unlock()
 
-	var off int64
 	switch whence {
-	case io.SeekStart:
-		off = offset
 	case io.SeekCurrent:
-		off = offset + br.offset
 		// 读取需要的部分到缓存
-		if err := br.readToBuf(off - int64(len(br.buf))); err != nil && err != io.EOF {
+		end := br.offset + offset
+		if err := br.fillTo(end, end); err != nil && err != io.EOF {
 			return br.offset, err
 		}
+		br.trim(end)
 	case io.SeekEnd:
 		// 将所有读入缓存
 		if err := br.readAll(); err != nil {
 			return br.offset, err
 		}
-		off = int64(len(br.buf)) + offset
 	}
-	if off < 0 || off > int64(len(br.buf)) {
-		return br.offset, errors.New("out of range")
+	off, err := resolveSeek(offset, whence, br.offset, br.store.Len())
+	if err != nil {
+		return br.offset, err
+	}
+	if off < br.start {
+		return br.offset, ErrOutsideWindow
 	}
 
 	br.offset = off
@@ -93,27 +218,281 @@ func (br *bufferReader) ReadAt(p []byte, off int64) (n int, err error) {
 		return 0, ErrNegativeOffset
 	}
 
-	br.lock.RLock()
-
-	// 需要读入缓存区的大小
-	needSize := func() int64 { return off + int64(len(p)-len(br.buf)) }
-
-	if need := needSize(); need > 0 {
+	end := off + int64(len(p))
+	for {
+		br.lock.RLock()
+		n, ok, err := br.readBuffered(p, off, end)
 		br.lock.RUnlock()
+		if ok {
+			return n, err
+		}
+
 		br.lock.Lock()
+		if off >= br.start {
+			br.fillTo(end, off)
+			br.trim(off)
+		}
+		br.lock.Unlock()
+	}
+}
 
-		if need := needSize(); need > 0 {
-			err = br.readToBuf(need)
+// 读取 off 处已缓存的数据，仅在没有数据时等待
+func (br *bufferReader) readAvailable(p []byte, off int64) (n int, err error) {
+	for {
+		br.lock.RLock()
+		avail := br.store.Len() - off
+		if avail > 0 && avail < int64(len(p)) {
+			p = p[:avail]
+		}
+		n, ok, err := br.readBuffered(p, off, off+1)
+		br.lock.RUnlock()
+		if ok {
+			return n, err
 		}
 
+		br.lock.Lock()
+		if off >= br.start {
+			br.waitFor(off + 1)
+		}
 		br.lock.Unlock()
-		br.lock.RLock()
 	}
-	if int64(len(br.buf)) >= off {
-		n = copy(p, br.buf[off:])
+}
+
+// 已缓存到 end 或读取结束时读取数据，调用时需持有锁（读锁即可）
+// ok 为 false 时需要等待数据
+func (br *bufferReader) readBuffered(p []byte, off, end int64) (n int, ok bool, err error) {
+	if off < br.start {
+		return 0, true, ErrOutsideWindow
+	}
+	if br.closed {
+		return 0, true, os.ErrClosed
+	}
+	if br.store.Len() < end && br.err == nil {
+		return 0, false, nil
+	}
+
+	n, err = br.store.ReadAt(p, off)
+	if n < len(p) && br.err != nil && br.err != io.EOF {
+		err = br.err
+	}
+	return n, true, err
+}
+
+// Close
+// 释放缓存，若r实现了 io.Closer 则一并关闭
+// 后台读取在r返回后结束
+func (br *bufferReader) Close() error {
+	br.lock.Lock()
+	br.closed = true
+	br.cond.Broadcast()
+	err := br.store.Close()
+	br.lock.Unlock()
+
+	if c, ok := br.r.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// bufferReader 的数据存储
+// Stage 由后台读取在不持有锁时调用，进行写入临时文件等耗时操作
+// Commit 在持有写锁时追加 Stage 后的数据，之后才能读取
+// Release 释放 off 之前的数据
+type bufferStore interface {
+	io.ReaderAt
+	io.Closer
+	Stage(p []byte) error
+	Commit(p []byte)
+	Len() int64
+	Release(off int64)
+}
+
+// 内存分块大小
+const bufferChunkSize = 32 * 1024
+
+var bufferChunkPool = pool.NewPoolCap(64, func() []byte {
+	return make([]byte, bufferChunkSize)
+})
+
+// 分块保存在内存
+// 追加时无需复制已有数据，释放和关闭时分块放回池中
+type memStore struct {
+	chunks   [][]byte // 除最后一块外均已写满
+	base     int64    // chunks[0] 起始位置
+	size     int64    // 数据大小
+	released int64    // 已释放位置
+}
+
+// 末尾未写满的分块
+func (s *memStore) tail() int {
+	last := len(s.chunks) - 1
+	if last < 0 || len(s.chunks[last]) == bufferChunkSize {
+		s.chunks = append(s.chunks, bufferChunkPool.Get()[:0])
+		last++
+	}
+	return last
+}
+
+func (s *memStore) Stage(p []byte) error { return nil }
+
+func (s *memStore) Commit(p []byte) {
+	for len(p) > 0 {
+		last := s.tail()
+		chunk := s.chunks[last]
+		wn := copy(chunk[len(chunk):bufferChunkSize], p)
+		s.chunks[last] = chunk[:len(chunk)+wn]
+
+		p = p[wn:]
+		s.size += int64(wn)
+	}
+}
+
+func (s *memStore) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < s.released {
+		return 0, ErrOutsideWindow
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	for len(p) > 0 && off < s.size {
+		index := (off - s.base) / bufferChunkSize
+		rn := copy(p, s.chunks[index][(off-s.base)%bufferChunkSize:])
+		p = p[rn:]
+		n += rn
+		off += int64(rn)
+	}
+	if len(p) > 0 {
+		err = io.EOF
 	}
-	br.lock.RUnlock()
 	return
 }
 
+func (s *memStore) Len() int64 { return s.size }
+
+// 仅回收已写满且完全位于 off 之前的分块
+func (s *memStore) Release(off int64) {
+	if off <= s.released {
+		return
+	}
+	s.released = off
+
+	for len(s.chunks) > 0 && len(s.chunks[0]) == bufferChunkSize && s.base+bufferChunkSize <= off {
+		bufferChunkPool.Put(s.chunks[0])
+		s.chunks[0] = nil
+		s.chunks = s.chunks[1:]
+		s.base += bufferChunkSize
+	}
+}
+
+func (s *memStore) Close() error {
+	for _, chunk := range s.chunks {
+		bufferChunkPool.Put(chunk)
+	}
+	*s = memStore{}
+	return nil
+}
+
+// 超出内存限制的部分写入临时文件
+type spillStore struct {
+	memStore
+	limit int64  // 内存中保留的大小
+	dir   string // 临时文件目录
+
+	lock    sync.Mutex // Stage 不持有 bufferReader 的锁，与 Close 互斥
+	closed  bool
+	file    *os.File
+	fileLen int64 // 已提交的文件大小，之后的数据不可读取
+}
+
+// p 中写入内存的大小
+func (s *spillStore) memPart(p []byte) int {
+	remain := s.limit - s.memStore.Len()
+	if remain <= 0 {
+		return 0
+	}
+	if int64(len(p)) < remain {
+		return len(p)
+	}
+	return int(remain)
+}
+
+// 将超出内存限制的部分写入临时文件，提交前不可读取
+func (s *spillStore) Stage(p []byte) (err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return os.ErrClosed
+	}
+	p = p[s.memPart(p):]
+	if len(p) == 0 {
+		return nil
+	}
+
+	if s.file == nil {
+		if s.file, err = os.CreateTemp(s.dir, "buffer-*"); err != nil {
+			return err
+		}
+	}
+	_, err = s.file.WriteAt(p, s.fileLen)
+	return err
+}
+
+func (s *spillStore) Commit(p []byte) {
+	n := s.memPart(p)
+	s.memStore.Commit(p[:n])
+	s.fileLen += int64(len(p) - n)
+}
+
+func (s *spillStore) ReadAt(p []byte, off int64) (n int, err error) {
+	if off >= s.Len() {
+		return 0, io.EOF
+	}
+	// 临时文件中可能有未提交的数据
+	if remain := s.Len() - off; remain < int64(len(p)) {
+		p, err = p[:remain], io.EOF
+	}
+
+	// 内存部分
+	if off < s.memStore.Len() {
+		rn, _ := s.memStore.ReadAt(p, off)
+		p = p[rn:]
+		off += int64(rn)
+		n += rn
+	}
+
+	// 文件部分
+	if len(p) > 0 {
+		fn, ferr := s.file.ReadAt(p, off-s.memStore.Len())
+		n += fn
+		if ferr != nil {
+			err = ferr
+		}
+	}
+	return n, err
+}
+
+func (s *spillStore) Len() int64 { return s.memStore.Len() + s.fileLen }
+
+// 临时文件无法释放头部，仅由 bufferReader 限制可读范围
+func (s *spillStore) Release(off int64) {}
+
+func (s *spillStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.memStore.Close()
+	if s.file != nil {
+		err := errors.Join(s.file.Close(), os.Remove(s.file.Name()))
+		s.file, s.fileLen = nil, 0
+		return err
+	}
+	return nil
+}
+
 var _ ReadSeekReaderAt = (*bufferReader)(nil)
@@ -3,87 +3,300 @@ package ioutils
 import (
 	"errors"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/foxxorcat/library-go/pool"
+	systemutil "github.com/foxxorcat/library-go/system"
 	lru "github.com/hashicorp/golang-lru/v2"
 )
 
+type ReaderAtBufferOption func(*ReaderAtBufferOptions)
+
+type ReaderAtBufferOptions struct {
+	TTL        time.Duration        // 缓存块有效期，0 表示永不过期
+	Revalidate func() (bool, error) // 缓存块过期时校验数据源是否变化
+}
+
+// SetBlockTTL 设置缓存块有效期
+// 未设置 SetRevalidate 时大小同样在过期后重新获取，以读取增长的数据源
+func SetBlockTTL(ttl time.Duration) ReaderAtBufferOption {
+	return func(o *ReaderAtBufferOptions) {
+		o.TTL = ttl
+	}
+}
+
+// SetRevalidate
+// 缓存块过期时调用fn，返回 true 表示数据源已变化，清空全部缓存
+// 未变化时所有缓存块重新计算有效期
+// 未设置时仅重新加载过期的块
+func SetRevalidate(fn func() (changed bool, err error)) ReaderAtBufferOption {
+	return func(o *ReaderAtBufferOptions) {
+		o.Revalidate = fn
+	}
+}
+
+// RevalidateBySize
+// 通过大小变化判断数据源是否变化
+func RevalidateBySize(r any) func() (bool, error) {
+	var lock sync.Mutex
+	last, _ := GetStreamSize(r, SetNoSideEffect(true))
+	return func() (bool, error) {
+		size, err := GetStreamSize(r, SetNoSideEffect(true))
+		if err != nil {
+			return false, err
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		changed := size != last
+		last = size
+		return changed, nil
+	}
+}
+
 // NewReaderAtBuffer
 // 基于lru为io.ReaderAt提供缓存支持
 // @param blockSize 缓存块大小
 // @param blockNum 缓存块数量
 // @return io.ReaderAt
-func NewReaderAtBuffer(r io.ReaderAt, blockSize int, blockNum int) *readerAtBuffer {
+func NewReaderAtBuffer(r io.ReaderAt, blockSize int, blockNum int, opts ...ReaderAtBufferOption) *readerAtBuffer {
+	var options ReaderAtBufferOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	pool := pool.NewPoolCap(blockNum, func() []byte {
 		return make([]byte, blockSize)
 	})
-	cache, err := lru.NewWithEvict(blockNum, func(key int, value []byte) {
-		pool.Put(value)
+	cache, err := lru.NewWithEvict(blockNum, func(key int, value cachedBlock) {
+		pool.Put(value.buf)
 	})
 	if err != nil {
 		panic(err)
 	}
 
-	return &readerAtBuffer{
+	br := &readerAtBuffer{
 		r:           r,
 		pool:        pool,
 		blockSize:   blockSize,
 		cacheBlocks: cache,
+		ttl:         options.TTL,
+		revalidate:  options.Revalidate,
+	}
+	br.size.Store(-1)
+	if size, err := GetStreamSize(r, SetNoSideEffect(true)); err == nil {
+		br.size.Store(size)
 	}
+	br.sized.Store(time.Now().UnixNano())
+	return br
+}
+
+type cachedBlock struct {
+	buf  []byte
+	time time.Time // 加载时间
 }
 
 type readerAtBuffer struct {
 	r           io.ReaderAt
+	size        atomic.Int64 // 数据大小，-1 表示未知
+	sized       atomic.Int64 // 获取大小的时间
 	pool        *pool.PoolChan[[]byte]
-	blockSize   int                     // 缓存块大小
-	cacheBlocks *lru.Cache[int, []byte] // 块缓存
+	blockSize   int                          // 缓存块大小
+	cacheBlocks *lru.Cache[int, cachedBlock] // 块缓存
+	lock        sync.Mutex                   // 保护 cacheBlocks 及缓存块内容
+
+	ttl          time.Duration
+	revalidate   func() (bool, error)
+	validated    atomic.Int64 // 最近一次校验时间
+	validateLock sync.Mutex   // 串行化校验
+}
+
+// 缓存块是否过期
+func (r *readerAtBuffer) expired(blk cachedBlock) bool {
+	if r.ttl <= 0 {
+		return false
+	}
+	last := blk.time
+	if validated := time.Unix(0, r.validated.Load()); validated.After(last) {
+		last = validated
+	}
+	return time.Since(last) >= r.ttl
+}
+
+// 清空缓存并刷新大小
+func (r *readerAtBuffer) invalidate() {
+	r.lock.Lock()
+	r.cacheBlocks.Purge()
+	r.lock.Unlock()
+	if size, err := GetStreamSize(r.r, SetNoSideEffect(true)); err == nil {
+		r.size.Store(size)
+	}
+}
+
+// 仅设置 TTL 时，大小过期后重新获取
+// 同一时间只有一个协程获取，其他协程沿用旧的大小
+func (r *readerAtBuffer) refreshSize() {
+	if r.ttl <= 0 || r.revalidate != nil {
+		return
+	}
+	last, now := r.sized.Load(), time.Now().UnixNano()
+	if time.Duration(now-last) < r.ttl || !r.sized.CompareAndSwap(last, now) {
+		return
+	}
+	if size, err := GetStreamSize(r.r, SetNoSideEffect(true)); err == nil {
+		r.size.Store(size)
+	}
+}
+
+// 校验数据源，变化时清空缓存
+// 同一时间只进行一次校验，等待期间其他协程已完成校验时直接返回
+// @param seen 发现块过期时的校验时间
+func (r *readerAtBuffer) revalidateBlocks(seen int64) error {
+	r.validateLock.Lock()
+	defer r.validateLock.Unlock()
+
+	if r.validated.Load() != seen {
+		return nil
+	}
+
+	changed, err := r.revalidate()
+	if err != nil {
+		return err
+	}
+	if changed {
+		r.invalidate()
+	}
+	r.validated.Store(time.Now().UnixNano())
+	return nil
+}
+
+// 从未过期的缓存块复制数据到p
+// expired 为 true 时表示块存在但已过期
+func (r *readerAtBuffer) readCached(index, offset int, p []byte, checkExpired bool) (n int, ok, expired bool, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	blk, ok := r.cacheBlocks.Get(index)
+	if !ok {
+		return 0, false, false, nil
+	}
+	// 数据源增长后末尾的块不完整
+	if len(blk.buf) < blockLen(index, r.blockSize, r.size.Load()) {
+		r.cacheBlocks.Remove(index)
+		return 0, false, false, nil
+	}
+	if checkExpired && r.expired(blk) {
+		if r.revalidate == nil {
+			r.cacheBlocks.Remove(index)
+			return 0, false, false, nil
+		}
+		return 0, false, true, nil
+	}
+	n, err = copyBlock(blk.buf, offset, p)
+	return n, true, false, err
 }
 
-// 加载块到缓存
-func (r *readerAtBuffer) loadBlock(index int) ([]byte, error) {
-	if buf, ok := r.cacheBlocks.Get(index); ok {
-		return buf, nil
+// 读取块，未缓存或已失效时加载到缓存
+// 加载时不持有锁，不阻塞其他读取
+func (r *readerAtBuffer) readBlock(index, offset int, p []byte) (int, error) {
+	seen := r.validated.Load()
+	n, ok, expired, err := r.readCached(index, offset, p, true)
+	if ok {
+		return n, err
+	}
+
+	if expired {
+		if err := r.revalidateBlocks(seen); err != nil {
+			return 0, err
+		}
+		// 未变化时沿用缓存，变化时缓存已清空
+		if n, ok, _, err := r.readCached(index, offset, p, false); ok {
+			return n, err
+		}
 	}
 
 	buf := r.pool.Get()
-	n, err := r.r.ReadAt(buf[:r.blockSize], int64(index)*int64(r.blockSize))
+	n, err = r.r.ReadAt(buf[:blockLen(index, r.blockSize, r.size.Load())], int64(index)*int64(r.blockSize))
 	if err != nil && err != io.EOF {
-		return nil, err
+		r.pool.Put(buf)
+		return 0, err
 	}
-	buf = buf[:n]
-	r.cacheBlocks.Add(index, buf)
-	return buf, nil
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cacheBlocks.Add(index, cachedBlock{buf: buf[:n], time: time.Now()})
+	return copyBlock(buf[:n], offset, p)
 }
 
 func (r *readerAtBuffer) ReadAt(p []byte, off int64) (rn int, err error) {
 	if off < 0 {
 		return 0, errors.New("negative offset")
 	}
+	r.refreshSize()
+	return readAtBlocks(p, off, r.size.Load(), r.blockSize, r.readBlock)
+}
+
+// Size 数据大小，-1 表示未知
+func (r *readerAtBuffer) Size() int64 {
+	return r.size.Load()
+}
+
+// 块的有效长度
+// size < 0 表示大小未知
+func blockLen(index int, blockSize int, size int64) int {
+	if size < 0 {
+		return blockSize
+	}
+	return int(systemutil.Max(systemutil.Min(size-int64(index)*int64(blockSize), blockSize), 0))
+}
 
-	index := int(off / int64(r.blockSize))  // 缓存块编号
-	offset := int(off % int64(r.blockSize)) //  缓存块偏移
-	for len(p) > 0 {
-		block, err := r.loadBlock(index)
-		if err != nil {
-			return rn, err
+// 按块读取数据，各缓存读取器共用
+// 源大小已知（size >= 0）时限制读取范围，读取到末尾时与数据一同返回 io.EOF，不再访问源
+// size < 0 时仅能通过不完整的块发现末尾
+// @param readBlock 从指定编号的缓存块 offset 处复制数据到p，可在持有锁时复制
+func readAtBlocks(p []byte, off int64, size int64, blockSize int, readBlock func(index, offset int, p []byte) (int, error)) (rn int, err error) {
+	var eof bool
+	if size >= 0 {
+		if off >= size {
+			return 0, io.EOF
 		}
-
-		// 读取范围超过 block（仅在读取末端时触发）
-		if offset >= len(block) {
-			return rn, io.EOF
+		if off+int64(len(p)) >= size {
+			p = p[:size-off]
+			eof = true
 		}
+	}
 
-		// 读取
-		n := copy(p, block[offset:])
+	index := int(off / int64(blockSize))  // 缓存块编号
+	offset := int(off % int64(blockSize)) //  缓存块偏移
+	for len(p) > 0 {
+		n, err := readBlock(index, offset, p)
 		p = p[n:]
 		rn += n
 		offset += n
+		if err != nil {
+			return rn, err
+		}
 
 		// 读取下一个块
-		if offset >= r.blockSize {
+		if offset >= blockSize {
 			index++
 			offset = 0
 		}
 	}
+	if eof {
+		return rn, io.EOF
+	}
 	return rn, nil
 }
+
+// 复制缓存块 offset 之后的数据到p
+// 读取范围超过块（仅在读取末端时触发）时返回 io.EOF
+// 淘汰的块会回到 pool 中复用，调用时需持有缓存的锁
+func copyBlock(block []byte, offset int, p []byte) (int, error) {
+	if offset >= len(block) {
+		return 0, io.EOF
+	}
+	return copy(p, block[offset:]), nil
+}
@@ -1,5 +1,22 @@
 package ioutils
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var ErrNegativeOffset = errors.New("negative offset")
+var ErrOutsideWindow = errors.New("offset outside window")
+var ErrLimitExceeded = errors.New("limit exceeded")
+var ErrUnknownSize = errors.New("unknown size")
+
+// 超出写入限制，Accepted 为本次写入接受的字节数
+type LimitExceededError struct {
+	Accepted int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("limit exceeded, accepted %d bytes", e.Accepted)
+}
+
+func (e *LimitExceededError) Is(target error) bool { return target == ErrLimitExceeded }
@@ -109,6 +109,9 @@ func (r *httpReader) Size() int64 {
 }
 
 func (r *httpReader) Read(p []byte) (n int, err error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
 	if r.r == nil {
 		if r.r, err = r.getReader(r.offset, r.size); err != nil {
 			return
@@ -128,13 +131,19 @@ func (r *httpReader) Seek(offset int64, whence int) (int64, error) {
 		off = r.offset + offset
 	case io.SeekEnd:
 		off = r.size + offset
+	default:
+		return r.offset, fmt.Errorf("invalid whence:%d", whence)
 	}
 
 	if off < 0 || off > r.size {
 		return r.offset, ErrOutRange
 	}
 
-	if r.offset != off {
+	// 末尾无需请求
+	if off == r.size {
+		_ = r.Close()
+		r.offset = off
+	} else if r.offset != off {
 		nr, err := r.getReader(off, r.size)
 		if err != nil {
 			return r.offset, err
@@ -151,6 +160,7 @@ func (r *httpReader) Seek(offset int64, whence int) (int64, error) {
 func (r *httpReader) Close() (err error) {
 	if r.r != nil {
 		err = r.r.Close()
+		r.r = nil
 	}
 	return
 }
@@ -173,7 +183,13 @@ func (r *httpReader) ReadAt(p []byte, off int64) (n int, err error) {
 	if err != nil {
 		return
 	}
-	return io.ReadFull(nr, p[:end-off])
+	defer nr.Close()
+
+	n, err = io.ReadFull(nr, p[:end-off])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return
 }
 
 var _ ioutils.SizeReadSeekReadAtCloser = (*httpReader)(nil)
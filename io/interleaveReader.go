@@ -0,0 +1,201 @@
+package ioutils
+
+import (
+	"bufio"
+	"io"
+)
+
+// 来源读取完毕时的处理方式
+type InterleavePolicy int
+
+const (
+	InterleaveStop InterleavePolicy = iota // 结束读取
+	InterleaveSkip                         // 跳过该来源
+	InterleavePad                          // 使用 Filler 填充该来源
+)
+
+type InterleavePart struct {
+	R      io.Reader
+	Stride int              // 每轮读取的字节数
+	OnEOF  InterleavePolicy // 读取完毕时的处理方式
+	Filler io.Reader        // InterleavePad 时的填充来源，为空时使用 Zero
+}
+
+// InterleaveReader
+// 按顺序轮流从各来源读取 Stride 字节
+// 所有来源读取完毕时返回 io.EOF，不会在末尾填充
+func InterleaveReader(parts ...InterleavePart) io.Reader {
+	r := &interleaveReader{}
+
+	var pad bool
+	for _, part := range parts {
+		if part.Stride <= 0 {
+			continue
+		}
+		if part.Filler == nil {
+			part.Filler = Zero
+		}
+		pad = pad || part.OnEOF == InterleavePad
+		r.parts = append(r.parts, interleavePart{InterleavePart: part, r: part.R})
+	}
+
+	// 填充前需要确认其他来源是否还有数据
+	if pad {
+		for i := range r.parts {
+			r.parts[i].r = bufio.NewReaderSize(r.parts[i].R, 16)
+		}
+	}
+
+	r.active = len(r.parts)
+	if r.active > 0 {
+		r.remain = r.parts[0].Stride
+	}
+	return r
+}
+
+type interleavePart struct {
+	InterleavePart
+	r   io.Reader
+	eof bool
+}
+
+type interleaveReader struct {
+	parts  []interleavePart
+	index  int  // 当前来源
+	remain int  // 当前来源本轮剩余字节数
+	active int  // 未读取完毕的来源数量
+	stop   bool // 已结束
+}
+
+// 切换到下一个来源
+func (r *interleaveReader) next() {
+	r.index = (r.index + 1) % len(r.parts)
+	r.remain = r.parts[r.index].Stride
+}
+
+// 标记来源读取完毕
+func (r *interleaveReader) finish(part *interleavePart) {
+	part.eof = true
+	r.active--
+	if part.OnEOF == InterleaveStop {
+		r.stop = true
+	}
+}
+
+// 是否还有来源可以读取，仅在存在填充时调用
+func (r *interleaveReader) pending() bool {
+	for i := range r.parts {
+		part := &r.parts[i]
+		if part.eof {
+			continue
+		}
+		if _, err := part.r.(*bufio.Reader).Peek(1); err != io.EOF {
+			return true
+		}
+		r.finish(part)
+	}
+	return false
+}
+
+func (r *interleaveReader) Read(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if r.stop || r.active == 0 {
+			return 0, io.EOF
+		}
+
+		part := &r.parts[r.index]
+		if part.eof && part.OnEOF == InterleaveSkip {
+			r.next()
+			continue
+		}
+
+		src := part.r
+		if part.eof {
+			// 其他来源均已读取完毕，不再填充
+			if !r.pending() || r.stop {
+				continue
+			}
+			src = part.Filler
+		}
+
+		q := p
+		if r.remain < len(q) {
+			q = q[:r.remain]
+		}
+		n, err = src.Read(q)
+		r.remain -= n
+
+		if err == io.EOF {
+			err = nil
+			if part.eof {
+				// 填充来源读取完毕，之后跳过
+				part.OnEOF = InterleaveSkip
+			} else {
+				r.finish(part)
+			}
+			if part.OnEOF == InterleaveSkip {
+				r.remain = 0
+			}
+		}
+		if r.remain == 0 {
+			r.next()
+		}
+
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+	return 0, nil
+}
+
+type DeinterleavePart struct {
+	W      io.Writer
+	Stride int // 每轮写入的字节数
+}
+
+// DeinterleaveWriter
+// InterleaveReader 的逆操作，按顺序轮流向各目标写入 Stride 字节
+func DeinterleaveWriter(parts ...DeinterleavePart) io.Writer {
+	w := &deinterleaveWriter{}
+	for _, part := range parts {
+		if part.Stride > 0 {
+			w.parts = append(w.parts, part)
+		}
+	}
+	if len(w.parts) > 0 {
+		w.remain = w.parts[0].Stride
+	}
+	return w
+}
+
+type deinterleaveWriter struct {
+	parts  []DeinterleavePart
+	index  int // 当前目标
+	remain int // 当前目标本轮剩余字节数
+}
+
+func (w *deinterleaveWriter) Write(p []byte) (n int, err error) {
+	if len(w.parts) == 0 {
+		return len(p), nil
+	}
+
+	for len(p) > 0 {
+		q := p
+		if w.remain < len(q) {
+			q = q[:w.remain]
+		}
+
+		wn, err := w.parts[w.index].W.Write(q)
+		n += wn
+		p = p[wn:]
+		w.remain -= wn
+		if w.remain == 0 {
+			w.index = (w.index + 1) % len(w.parts)
+			w.remain = w.parts[w.index].Stride
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
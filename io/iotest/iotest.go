@@ -0,0 +1,322 @@
+// iotest 提供 ioutils 接口的一致性测试
+// 用于验证各种适配器是否遵守 io 接口约定
+package iotest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	ioutils "github.com/foxxorcat/library-go/io"
+	randomutils "github.com/foxxorcat/library-go/random"
+	systemutil "github.com/foxxorcat/library-go/system"
+	"github.com/pkg/errors"
+)
+
+// 连续返回 0, nil 的最大次数
+const maxEmptyReads = 100
+
+// TestReader
+// 以随机大小的缓冲区读取r，检查内容与want一致
+// 读取完毕后必须返回 0, io.EOF
+func TestReader(r io.Reader, want []byte) error {
+	var (
+		got   []byte
+		buf   [4096]byte
+		empty int
+	)
+	for {
+		p := buf[:1+randomutils.FastRandn(uint32(len(buf)))]
+		n, err := r.Read(p)
+		if n < 0 || n > len(p) {
+			return errors.Errorf("Read 返回的 n=%d 超过正常范围 [0, %d]", n, len(p))
+		}
+		got = append(got, p[:n]...)
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.WithMessagef(err, "读取到 %d 时出错", len(got))
+		}
+
+		if n == 0 {
+			if empty++; empty > maxEmptyReads {
+				return errors.Errorf("Read 连续 %d 次返回 0, nil", empty)
+			}
+		} else {
+			empty = 0
+		}
+	}
+
+	if err := compare(got, want, 0); err != nil {
+		return err
+	}
+
+	if n, err := r.Read(buf[:]); n != 0 || err != io.EOF {
+		return errors.Errorf("读取完毕后应返回 0, io.EOF, 实际 n=%d err=%v", n, err)
+	}
+	return nil
+}
+
+// TestReaderAt
+// 检查 io.ReaderAt 的约定
+// 1. n < len(p) 时必须返回错误，范围内的读取不能读取不足
+// 2. 读取到末尾时返回 io.EOF
+// 3. 超出范围或负数偏移返回 n=0 及错误
+// 4. 内容与want一致
+func TestReaderAt(r io.ReaderAt, want []byte) error {
+	size := int64(len(want))
+	var buf [4096]byte
+
+	/* 随机读取 */
+	for i := 0; i < rounds(size); i++ {
+		off := int64(0)
+		if size > 0 {
+			off = int64(randomutils.FastRandn(uint32(size)))
+		}
+		if err := checkReadAt(r, want, buf[:randomutils.FastRandn(uint32(len(buf)+1))], off); err != nil {
+			return err
+		}
+	}
+
+	/* 边界 */
+	for _, off := range []int64{0, size - 1, size - int64(len(buf)), size - int64(len(buf)) + 1} {
+		if off < 0 || off >= size {
+			continue
+		}
+		if err := checkReadAt(r, want, buf[:], off); err != nil {
+			return err
+		}
+	}
+
+	/* 非法值测试 */
+	if n, err := r.ReadAt(buf[:], size); n != 0 || err != io.EOF {
+		return errors.Errorf("在末尾读取应返回 0, io.EOF, 实际 n=%d err=%v", n, err)
+	}
+	if n, err := r.ReadAt(buf[:], size+1); n != 0 || err == nil {
+		return errors.Errorf("超过范围的读取应该返回错误, 并且保证 n==0, 实际 n=%d err=%v", n, err)
+	}
+	if n, err := r.ReadAt(buf[:], -1); n != 0 || err == nil {
+		return errors.Errorf("负数偏移的读取应该返回错误, 并且保证 n==0, 实际 n=%d err=%v", n, err)
+	}
+
+	/* 内容测试 */
+	got := make([]byte, 0, size)
+	for off := int64(0); off < size; {
+		p := buf[:1+randomutils.FastRandn(uint32(len(buf)))]
+		n, err := r.ReadAt(p, off)
+		got = append(got, p[:n]...)
+		off += int64(n)
+		if err != nil {
+			if err != io.EOF {
+				return errors.WithMessagef(err, "内容测试读取 %d 时出错", off)
+			}
+			break
+		}
+	}
+	return compare(got, want, 0)
+}
+
+// 检查单次 ReadAt
+func checkReadAt(r io.ReaderAt, want, p []byte, off int64) error {
+	size := int64(len(want))
+	n, err := r.ReadAt(p, off)
+	if n < 0 || n > len(p) {
+		return errors.Errorf("ReadAt(len=%d, off=%d) 返回的 n=%d 超过正常范围", len(p), off, n)
+	}
+
+	// n != len(p) 必然返回错误
+	if n < len(p) && err == nil {
+		return errors.Errorf("ReadAt实现有误, (n=%d) != (len(p)=%d) 但 err=nil, off=%d", n, len(p), off)
+	}
+
+	expect := systemutil.Min(int64(len(p)), size-off)
+	if int64(n) != expect {
+		return errors.Errorf("ReadAt(len=%d, off=%d) 读取不足, n=%d 应为 %d, err=%v", len(p), off, n, expect, err)
+	}
+
+	// 读满时只能返回 nil 或 io.EOF，且仅在末尾返回 io.EOF
+	if n == len(p) && err != nil && (err != io.EOF || off+int64(n) != size) {
+		return errors.Errorf("ReadAt(len=%d, off=%d) 已读满，但返回非法错误 %v", len(p), off, err)
+	}
+	// 读取到末尾之后只能返回 io.EOF
+	if n < len(p) && err != io.EOF {
+		return errors.Errorf("ReadAt(len=%d, off=%d) 读取到末尾应返回 io.EOF, 实际 %v", len(p), off, err)
+	}
+	return compare(p[:n], want[off:off+int64(n)], off)
+}
+
+// TestSizeReaderAt
+// 检查 Size() 与want大小一致，并执行 TestReaderAt
+func TestSizeReaderAt(r ioutils.SizeReaderAt, want []byte) error {
+	if r.Size() != int64(len(want)) {
+		return errors.Errorf("大小错误,实际大小:%d != %d", r.Size(), len(want))
+	}
+	return TestReaderAt(r, want)
+}
+
+// TestConcurrentReadAt
+// 使用 workers 个协程同时随机读取，需配合 -race 使用
+func TestConcurrentReadAt(r io.ReaderAt, want []byte, workers int) error {
+	size := int64(len(want))
+	if size == 0 {
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		rerr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf [4096]byte
+			for j := 0; j < 32; j++ {
+				off := int64(randomutils.FastRandn(uint32(size)))
+				if err := checkReadAt(r, want, buf[:randomutils.FastRandn(uint32(len(buf)+1))], off); err != nil {
+					once.Do(func() { rerr = errors.WithMessage(err, "并发读取") })
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return rerr
+}
+
+// TestReadSeeker
+// 检查 Seek 的结果与边界，失败的 Seek 不能改变位置
+// 之后从头执行 TestReader
+func TestReadSeeker(r io.ReadSeeker, want []byte) error {
+	size := int64(len(want))
+
+	n, err := ioutils.StreamSizeBySeeking(r, true)
+	if err != nil {
+		return errors.WithMessage(err, "StreamSizeBySeeking")
+	}
+	if n != size {
+		return errors.Errorf("seek 获取大小与数据大小不符合 %d != %d", n, size)
+	}
+
+	var buf [4096]byte
+
+	// Seek 后读取
+	for i := 0; i < rounds(size); i++ {
+		var off, noff int64
+		switch i % 3 {
+		case 0:
+			off = int64(randomutils.FastRandn(uint32(size + 1)))
+			noff, err = r.Seek(off, io.SeekStart)
+		case 1:
+			cur, _ := r.Seek(0, io.SeekCurrent)
+			off = int64(randomutils.FastRandn(uint32(size + 1)))
+			noff, err = r.Seek(off-cur, io.SeekCurrent)
+		case 2:
+			off = int64(randomutils.FastRandn(uint32(size + 1)))
+			noff, err = r.Seek(off-size, io.SeekEnd)
+		}
+		if err != nil || noff != off {
+			return errors.Errorf("Seek错误 noff:%d != off:%d, err=%v", noff, off, err)
+		}
+
+		n, err := io.ReadFull(r, buf[:randomutils.FastRandn(uint32(len(buf)+1))])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return errors.WithMessagef(err, "Seek 到 %d 后读取出错", off)
+		}
+		if err := compare(buf[:n], want[off:off+int64(n)], off); err != nil {
+			return err
+		}
+	}
+
+	// 读文件末尾
+	if _, err := r.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if n, err := r.Read(buf[:]); n != 0 || err != io.EOF {
+		return errors.Errorf("读取文件末尾应该返回 0, io.EOF, 实际 n=%d err=%v", n, err)
+	}
+
+	/* 非法值测试 */
+	pos := size / 2
+	if _, err := r.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	invalid := []struct {
+		offset int64
+		whence int
+	}{
+		{-1, io.SeekStart},
+		{-pos - 1, io.SeekCurrent},
+		{-size - 1, io.SeekEnd},
+		{0, 42},
+	}
+	for _, v := range invalid {
+		if _, err := r.Seek(v.offset, v.whence); err == nil {
+			return errors.Errorf("Seek(%d, %s) 非法参数，应该返回错误", v.offset, whenceName(v.whence))
+		}
+		if cur, err := r.Seek(0, io.SeekCurrent); err != nil || cur != pos {
+			return errors.Errorf("失败的 Seek(%d, %s) 改变了位置 %d -> %d, err=%v", v.offset, whenceName(v.whence), pos, cur, err)
+		}
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return TestReader(r, want)
+}
+
+// TestClose
+// 检查第一次 Close 成功，重复 Close 不能 panic
+// 第二次 Close 只能返回 nil 或 os.ErrClosed
+func TestClose(c io.Closer) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = errors.Errorf("重复 Close 时 panic: %v", v)
+		}
+	}()
+
+	if err := c.Close(); err != nil {
+		return errors.WithMessage(err, "Close")
+	}
+	if err := c.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return errors.WithMessage(err, "重复 Close")
+	}
+	return nil
+}
+
+// 随机测试次数
+func rounds(size int64) int {
+	return int(systemutil.Log(systemutil.Max(size, 1))) + 16
+}
+
+// 比较内容，返回第一个不同的位置
+func compare(got, want []byte, base int64) error {
+	if bytes.Equal(got, want) {
+		return nil
+	}
+	i := 0
+	for i < len(got) && i < len(want) && got[i] == want[i] {
+		i++
+	}
+	if len(got) != len(want) && i == systemutil.Min(len(got), len(want)) {
+		return errors.Errorf("读取内容错误, 长度 %d != %d", len(got), len(want))
+	}
+	return errors.Errorf("读取内容错误, 位置 %d 处 %#x != %#x", base+int64(i), got[i], want[i])
+}
+
+func whenceName(whence int) string {
+	switch whence {
+	case io.SeekStart:
+		return "SeekStart"
+	case io.SeekCurrent:
+		return "SeekCurrent"
+	case io.SeekEnd:
+		return "SeekEnd"
+	}
+	return fmt.Sprint(whence)
+}
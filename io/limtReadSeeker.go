@@ -7,11 +7,20 @@ import (
 )
 
 /* 限制 io.ReadSeek 读取范围 */
+// 读取时才定位r并校验位置，不依赖r的当前位置
+// 若r实现了 io.ReaderAt，返回值同时实现 io.ReaderAt
 func LimitReadSeeker(r io.ReadSeeker, offset, size int64) SizeReadSeeker {
 	lr := &limitReadSeeker{
 		r:      r,
 		offset: offset,
 		size:   size,
+		pos:    -1,
+	}
+	if ra, ok := r.(io.ReaderAt); ok {
+		return &limitReadSeekReaderAt{
+			limitReadSeeker: lr,
+			section:         SectionReaderAt(ra, offset, size),
+		}
 	}
 	return lr
 }
@@ -21,6 +30,26 @@ type limitReadSeeker struct {
 	offset int64
 	index  int64
 	size   int64
+	pos    int64 // r 相对 offset 的位置，-1 表示未知
+}
+
+// 将r定位到 index
+func (lr *limitReadSeeker) locate() error {
+	if lr.pos == lr.index {
+		return nil
+	}
+
+	want := lr.offset + lr.index
+	n, err := lr.r.Seek(want, io.SeekStart)
+	if err == nil && n != want {
+		err = errors.Errorf("seek to %d but got %d", want, n)
+	}
+	if err != nil {
+		lr.pos = -1
+		return err
+	}
+	lr.pos = lr.index
+	return nil
 }
 
 func (lr *limitReadSeeker) Read(p []byte) (n int, err error) {
@@ -30,34 +59,40 @@ func (lr *limitReadSeeker) Read(p []byte) (n int, err error) {
 	if i := (lr.size - lr.index); i < int64(len(p)) {
 		p = p[:i]
 	}
+	if err = lr.locate(); err != nil {
+		return 0, err
+	}
+
 	n, err = lr.r.Read(p)
 	lr.index += int64(n)
+	lr.pos += int64(n)
+	if err != nil && err != io.EOF {
+		lr.pos = -1
+	}
 	return
 }
 
+// Seek 仅记录位置，下次读取时定位r
 func (lr *limitReadSeeker) Seek(offset int64, whence int) (int64, error) {
-	var off int64
-	switch whence {
-	case io.SeekStart:
-		off = offset
-	case io.SeekCurrent:
-		off = lr.index + offset
-	case io.SeekEnd:
-		off = lr.size + offset
-	}
-
-	if off < 0 || off > lr.size {
-		return lr.index, errors.Errorf("out of range off:%d",off)
-	}
-
-	if lr.index != off {
-		n, err := lr.r.Seek(lr.offset+off, io.SeekStart)
-		lr.index = n - lr.offset
+	off, err := resolveSeek(offset, whence, lr.index, lr.size)
+	if err != nil {
 		return lr.index, err
 	}
+	lr.index = off
 	return lr.index, nil
 }
 
 func (lr *limitReadSeeker) Size() int64 {
 	return lr.size
 }
+
+type limitReadSeekReaderAt struct {
+	*limitReadSeeker
+	section ReaderAt
+}
+
+func (lr *limitReadSeekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return lr.section.ReadAt(p, off)
+}
+
+var _ SizeReadSeekReaderAt = (*limitReadSeekReaderAt)(nil)
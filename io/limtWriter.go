@@ -1,10 +1,16 @@
 package ioutils
 
-import "io"
+import (
+	"io"
+	"math"
+
+	systemutil "github.com/foxxorcat/library-go/system"
+)
 
 type limitWriter struct {
-	w     io.Writer
-	limit int64
+	w      io.Writer
+	limit  int64
+	strict bool // 超出时返回 *LimitExceededError
 }
 
 func (l *limitWriter) Write(p []byte) (n int, err error) {
@@ -13,12 +19,84 @@ func (l *limitWriter) Write(p []byte) (n int, err error) {
 		if int64(lp) > l.limit {
 			p = p[:l.limit]
 		}
-		l.limit -= int64(len(p))
-		_, err = l.w.Write(p)
+		n, err = l.w.Write(p)
+		l.limit -= int64(n)
+	} else {
+		p = p[:0]
+	}
+
+	if !l.strict {
+		return lp, err
+	}
+	if len(p) < lp && err == nil {
+		err = &LimitExceededError{Accepted: n}
 	}
-	return lp, err
+	return n, err
 }
 
-func LimitWriter(w io.Writer, limit int64) io.Writer {
+// Remaining 剩余可写入大小
+func (l *limitWriter) Remaining() int64 {
+	return l.limit
+}
+
+// LimitWriter
+// 超出 limit 的部分丢弃，并视为写入成功
+func LimitWriter(w io.Writer, limit int64) *limitWriter {
 	return &limitWriter{w: w, limit: limit}
 }
+
+// StrictLimitWriter
+// 超出 limit 时写入允许的部分，并返回 *LimitExceededError
+func StrictLimitWriter(w io.Writer, limit int64) *limitWriter {
+	return &limitWriter{w: w, limit: limit, strict: true}
+}
+
+// LimitWriterAt
+// 限制写入 io.WriterAt 的 [off, off+size) 部分，偏移相对于 off
+// 超出范围时写入允许的部分，并返回 *LimitExceededError
+func LimitWriterAt(w io.WriterAt, off, size int64) *limitWriterAt {
+	return &limitWriterAt{w: w, base: off, size: size}
+}
+
+// OffsetWriterAt
+// 从 off 开始写入 io.WriterAt，偏移相对于 off
+func OffsetWriterAt(w io.WriterAt, off int64) *limitWriterAt {
+	return LimitWriterAt(w, off, math.MaxInt64-off)
+}
+
+type limitWriterAt struct {
+	w    io.WriterAt
+	base int64 // 在w中的起始位置
+	size int64
+	off  int64 // Write 位置
+}
+
+func (l *limitWriterAt) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	lp := len(p)
+	if remain := l.size - off; remain < int64(lp) {
+		p = p[:systemutil.Max(remain, 0)]
+	}
+	if len(p) > 0 {
+		n, err = l.w.WriteAt(p, l.base+off)
+	}
+	if len(p) < lp && err == nil {
+		err = &LimitExceededError{Accepted: n}
+	}
+	return
+}
+
+// Write 从上次写入的末尾继续写入
+func (l *limitWriterAt) Write(p []byte) (n int, err error) {
+	n, err = l.WriteAt(p, l.off)
+	l.off += int64(n)
+	return
+}
+
+// Remaining 顺序写入时剩余可写入大小
+func (l *limitWriterAt) Remaining() int64 {
+	return l.size - l.off
+}
@@ -2,33 +2,138 @@ package ioutils
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"sort"
+	"sync"
 
 	systemutil "github.com/foxxorcat/library-go/system"
 )
 
+type MultiCloserOption func(*MultiCloserOptions)
+
+type MultiCloserOptions struct {
+	LIFO    bool // 按逆序关闭，与 defer 顺序相同
+	Once    bool // 仅关闭一次，之后返回相同的结果
+	SkipNil bool // 忽略为 nil 的 Closer
+}
+
+func CloseLIFO(lifo bool) MultiCloserOption {
+	return func(o *MultiCloserOptions) {
+		o.LIFO = lifo
+	}
+}
+
+func CloseOnce(once bool) MultiCloserOption {
+	return func(o *MultiCloserOptions) {
+		o.Once = once
+	}
+}
+
+func SkipNilCloser(skip bool) MultiCloserOption {
+	return func(o *MultiCloserOptions) {
+		o.SkipNil = skip
+	}
+}
+
+// 关闭失败的 Closer 及其索引
+type CloseError struct {
+	Index int
+	Err   error
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("close %d: %s", e.Index, e.Err)
+}
+
+func (e *CloseError) Unwrap() error { return e.Err }
+
 // 合并多个关闭接口
 func MultiCloser(closes ...io.Closer) *multiCloser {
-	return &multiCloser{
-		closes: closes,
+	return NewMultiCloser(closes)
+}
+
+// NewMultiCloser
+// 合并多个关闭接口，关闭全部后返回所有错误
+// 每个错误为 *CloseError
+func NewMultiCloser(closes []io.Closer, opts ...MultiCloserOption) *multiCloser {
+	m := &multiCloser{closes: closes}
+	for _, opt := range opts {
+		opt(&m.options)
 	}
+	return m
 }
 
 type multiCloser struct {
-	closes []io.Closer
+	closes  []io.Closer
+	options MultiCloserOptions
+
+	once sync.Once
+	err  error
 }
 
 func (m *multiCloser) Close() error {
+	if m.options.Once {
+		m.once.Do(func() { m.err = m.close() })
+		return m.err
+	}
+	return m.close()
+}
+
+func (m *multiCloser) close() error {
 	errs := make([]error, 0, len(m.closes))
-	for _, close := range m.closes {
-		errs = append(errs, close.Close())
+	for i := range m.closes {
+		if m.options.LIFO {
+			i = len(m.closes) - 1 - i
+		}
+
+		close := m.closes[i]
+		if close == nil && m.options.SkipNil {
+			continue
+		}
+		if err := close.Close(); err != nil {
+			errs = append(errs, &CloseError{Index: i, Err: err})
+		}
 	}
 	return errors.Join(errs...)
 }
 
 // 合并多个 SizeReaderAt 接口
-func MultiReaderAt(parts ...SizeReaderAt) SizeReaderAt {
+// Part 实际数据少于 Size() 时返回 io.ErrUnexpectedEOF
+// Part 大小未知（Size() < 0）时无法计算偏移，会 panic
+// 关闭时一并关闭实现了 io.Closer 的Part
+func MultiReaderAt(parts ...SizeReaderAt) SizeReaderAtCloser {
+	return newMultiReaderAt(parts)
+}
+
+// 合并多个 SizeReaderAt 接口
+// 读取跨越多个Part时并发读取，最多同时读取 limit 个Part
+func ParallelMultiReaderAt(limit int, parts ...SizeReaderAt) SizeReaderAtCloser {
+	m := newMultiReaderAt(parts)
+	m.limit = limit
+	return m
+}
+
+// 合并多个 SizeReaderAt 接口，并支持 io.ReadSeeker
+func MultiReadSeeker(parts ...SizeReaderAt) SizeReadSeekReadAtCloser {
+	return &multiReadSeeker{multiReaderAt: newMultiReaderAt(parts)}
+}
+
+// 检查Part大小是否已知
+func checkPartSizes(parts []SizeReaderAt) error {
+	for i, p := range parts {
+		if p.Size() < 0 {
+			return fmt.Errorf("part %d: %w", i, ErrUnknownSize)
+		}
+	}
+	return nil
+}
+
+func newMultiReaderAt(parts []SizeReaderAt) *multiReaderAt {
+	if err := checkPartSizes(parts); err != nil {
+		panic(err)
+	}
+
 	m := &multiReaderAt{parts: make([]offsetPart, 0, len(parts))}
 	for _, p := range parts {
 		m.parts = append(m.parts, offsetPart{m.size, p})
@@ -45,6 +150,27 @@ type offsetPart struct {
 type multiReaderAt struct {
 	parts []offsetPart
 	size  int64
+	limit int // 并发读取数量
+}
+
+// 单个Part的读取范围
+type partRead struct {
+	part SizeReaderAt
+	off  int64
+	p    []byte
+
+	n   int
+	err error
+}
+
+// 读取该Part，返回是否读满
+func (r *partRead) read() bool {
+	r.n, r.err = r.part.ReadAt(r.p, r.off)
+	// io.ReadAt规定 未读满p,必然返回错误
+	if r.n < len(r.p) && (r.err == nil || r.err == io.EOF) {
+		r.err = io.ErrUnexpectedEOF
+	}
+	return r.n == len(r.p)
 }
 
 func (m *multiReaderAt) ReadAt(p []byte, offset int64) (rn int, err error) {
@@ -57,6 +183,33 @@ func (m *multiReaderAt) ReadAt(p []byte, offset int64) (rn int, err error) {
 		return 0, io.EOF
 	}
 
+	reads := m.partReads(p, offset)
+	if m.limit > 1 && len(reads) > 1 {
+		m.readParallel(reads)
+	} else {
+		for i := range reads {
+			if !reads[i].read() {
+				break
+			}
+		}
+	}
+
+	for _, r := range reads {
+		rn += r.n
+		if r.n < len(r.p) {
+			return rn, r.err
+		}
+	}
+
+	// 所有Part读取完毕
+	if rn < len(p) {
+		return rn, io.EOF
+	}
+	return rn, nil
+}
+
+// 拆分为各Part的读取范围
+func (m *multiReaderAt) partReads(p []byte, offset int64) (reads []partRead) {
 	// 查找开始Part
 	indexParts := sort.Search(len(m.parts), func(i int) bool {
 		return m.parts[i].off+m.parts[i].Size() > offset
@@ -67,31 +220,64 @@ func (m *multiReaderAt) ReadAt(p []byte, offset int64) (rn int, err error) {
 		offset -= m.parts[indexParts].off
 	}
 
-	for len(p) != 0 {
-		// TODO io.ReadAt规定 未读满p,必然返回错误。读满也可能返回io.EOF
-
-		// 所有Part读取完毕
-		if indexParts >= len(m.parts) {
-			return rn, io.EOF
+	for ; len(p) != 0 && indexParts < len(m.parts); indexParts++ {
+		part := m.parts[indexParts]
+		if want := int(systemutil.Min(part.Size()-offset, len(p))); want > 0 {
+			reads = append(reads, partRead{part: part.SizeReaderAt, off: offset, p: p[:want]})
+			p = p[want:]
 		}
+		offset = 0
+	}
+	return
+}
 
-		// 读取该Part
-		part := m.parts[indexParts]
-		n, err := part.ReadAt(p[:systemutil.Min(part.Size()-offset, len(p))], offset)
+// 并发读取各Part
+func (m *multiReaderAt) readParallel(reads []partRead) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, m.limit)
+	for i := range reads {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *partRead) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r.read()
+		}(&reads[i])
+	}
+	wg.Wait()
+}
 
-		rn += n
-		p = p[n:]
+func (m *multiReaderAt) Size() (s int64) { return m.size }
 
-		// 当错误不属于读取到文件末尾时
-		if err != nil && err != io.EOF {
-			return rn, err
+// Close 关闭实现了 io.Closer 的Part，错误中的索引为Part索引
+func (m *multiReaderAt) Close() error {
+	closes := make([]io.Closer, len(m.parts))
+	for i, p := range m.parts {
+		if c, ok := p.SizeReaderAt.(io.Closer); ok {
+			closes[i] = c
 		}
-
-		// 下一部分
-		indexParts++
-		offset = 0
 	}
-	return rn, nil
+	return NewMultiCloser(closes, SkipNilCloser(true)).Close()
 }
 
-func (m *multiReaderAt) Size() (s int64) { return m.size }
+type multiReadSeeker struct {
+	*multiReaderAt
+	off int64
+}
+
+func (m *multiReadSeeker) Read(p []byte) (n int, err error) {
+	n, err = m.ReadAt(p, m.off)
+	m.off += int64(n)
+	return
+}
+
+func (m *multiReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	off, err := resolveSeek(offset, whence, m.off, m.size)
+	if err != nil {
+		return m.off, err
+	}
+	m.off = off
+	return m.off, nil
+}
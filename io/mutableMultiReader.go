@@ -0,0 +1,119 @@
+package ioutils
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// NewMutableMultiReaderAt
+// 可增删Part的 MultiReaderAt，修改时重新计算偏移，不复制数据
+// 修改与读取可并发进行，每次读取使用调用时的Part列表
+// Part 大小未知时返回 ErrUnknownSize
+func NewMutableMultiReaderAt(parts ...SizeReaderAt) (*mutableMultiReaderAt, error) {
+	m := &mutableMultiReaderAt{}
+	if err := m.Append(parts...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type mutableMultiReaderAt struct {
+	lock  sync.Mutex // 串行化修改
+	parts []SizeReaderAt
+	m     atomic.Pointer[multiReaderAt]
+}
+
+// 重新计算偏移，调用时需持有锁
+func (m *mutableMultiReaderAt) rebuild() {
+	m.m.Store(newMultiReaderAt(m.parts))
+}
+
+func (m *mutableMultiReaderAt) checkIndex(i, n int) error {
+	if i < 0 || i >= n {
+		return errors.Errorf("part index out of range:%d", i)
+	}
+	return nil
+}
+
+// Append 追加Part
+func (m *mutableMultiReaderAt) Append(parts ...SizeReaderAt) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := checkPartSizes(parts); err != nil {
+		return err
+	}
+	m.parts = append(m.parts, parts...)
+	m.rebuild()
+	return nil
+}
+
+// Insert 在第i个Part之前插入，i == Len() 时等同于 Append
+func (m *mutableMultiReaderAt) Insert(i int, parts ...SizeReaderAt) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.checkIndex(i, len(m.parts)+1); err != nil {
+		return err
+	}
+	if err := checkPartSizes(parts); err != nil {
+		return err
+	}
+	np := make([]SizeReaderAt, 0, len(m.parts)+len(parts))
+	np = append(np, m.parts[:i]...)
+	np = append(np, parts...)
+	m.parts = append(np, m.parts[i:]...)
+	m.rebuild()
+	return nil
+}
+
+// Remove 移除第i个Part
+func (m *mutableMultiReaderAt) Remove(i int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.checkIndex(i, len(m.parts)); err != nil {
+		return err
+	}
+	m.parts = append(m.parts[:i], m.parts[i+1:]...)
+	m.rebuild()
+	return nil
+}
+
+// Replace 替换第i个Part
+func (m *mutableMultiReaderAt) Replace(i int, part SizeReaderAt) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.checkIndex(i, len(m.parts)); err != nil {
+		return err
+	}
+	if err := checkPartSizes([]SizeReaderAt{part}); err != nil {
+		return err
+	}
+	m.parts[i] = part
+	m.rebuild()
+	return nil
+}
+
+// Len Part数量
+func (m *mutableMultiReaderAt) Len() int {
+	return len(m.m.Load().parts)
+}
+
+func (m *mutableMultiReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return m.m.Load().ReadAt(p, off)
+}
+
+func (m *mutableMultiReaderAt) Size() int64 {
+	return m.m.Load().Size()
+}
+
+// Close 关闭当前实现了 io.Closer 的Part
+func (m *mutableMultiReaderAt) Close() error {
+	return m.m.Load().Close()
+}
+
+var _ SizeReaderAtCloser = (*mutableMultiReaderAt)(nil)
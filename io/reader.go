@@ -53,3 +53,10 @@ type SizeReadSeekReadAtCloser interface {
 	ReadSeekReaderAt
 	Closer
 }
+
+/* ReadWriterAt */
+type WriterAt = io.WriterAt
+type ReadWriterAt interface {
+	ReaderAt
+	WriterAt
+}
@@ -4,14 +4,22 @@ import (
 	"bytes"
 	"hash/crc32"
 	"io"
+	"math"
 	"net/http"
 	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	stdiotest "testing/iotest"
+	"time"
 
 	ioutils "github.com/foxxorcat/library-go/io"
 	http_reader "github.com/foxxorcat/library-go/io/httpReader"
+	"github.com/foxxorcat/library-go/io/iotest"
 	randomutils "github.com/foxxorcat/library-go/random"
-	systemutil "github.com/foxxorcat/library-go/system"
 	"github.com/pkg/errors"
 )
 
@@ -23,13 +31,295 @@ func TestMultiReaderAt(t *testing.T) {
 	data3 := randomutils.RandomBytes(1024 * 1024)
 	r3 := bytes.NewReader(data3)
 
-	hash := crc32.NewIEEE()
-	hash.Write(data1)
-	hash.Write(data2)
-	hash.Write(data3)
-
 	mr := ioutils.MultiReaderAt(r1, r2, r3)
-	if err := testSizeReadAt(mr, int64(len(data1)+len(data2)+len(data3)), hash.Sum32()); err != nil {
+	if err := iotest.TestSizeReaderAt(mr, bytes.Join([][]byte{data1, data2, data3}, nil)); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestConcurrentReadAt(mr, bytes.Join([][]byte{data1, data2, data3}, nil), 8); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestClose(mr); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMultiReaderAtUnknownSize(t *testing.T) {
+	unknown := ioutils.NewReaderAtBuffer(struct{ io.ReaderAt }{bytes.NewReader(make([]byte, 100))}, 16, 4)
+	if _, err := ioutils.GetStreamSize(unknown); err == nil {
+		t.Error("大小未知时 GetStreamSize 应返回错误")
+	}
+
+	mr, err := ioutils.NewMutableMultiReaderAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Insert(0, unknown); !errors.Is(err, ioutils.ErrUnknownSize) {
+		t.Errorf("Insert 大小未知的Part应返回 ErrUnknownSize, err=%v", err)
+	}
+	if err := mr.Append(unknown); !errors.Is(err, ioutils.ErrUnknownSize) || mr.Len() != 0 {
+		t.Errorf("Append 大小未知的Part应返回 ErrUnknownSize, err=%v", err)
+	}
+	if _, err := ioutils.NewMutableMultiReaderAt(unknown); !errors.Is(err, ioutils.ErrUnknownSize) {
+		t.Errorf("NewMutableMultiReaderAt 大小未知的Part应返回 ErrUnknownSize, err=%v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MultiReaderAt 大小未知的Part应 panic")
+		}
+	}()
+	ioutils.MultiReaderAt(unknown, bytes.NewReader(make([]byte, 10)))
+}
+
+func TestParallelMultiReaderAt(t *testing.T) {
+	var (
+		parts   []ioutils.SizeReaderAt
+		data    []byte
+		running atomic.Int32
+		max     atomic.Int32
+	)
+	for i := 0; i < 8; i++ {
+		d := randomutils.RandomBytes(64 * 1024)
+		data = append(data, d...)
+		parts = append(parts, &slowReaderAt{Reader: bytes.NewReader(d), running: &running, max: &max})
+	}
+
+	mr := ioutils.ParallelMultiReaderAt(4, parts...)
+	if err := iotest.TestSizeReaderAt(mr, data); err != nil {
+		t.Error(err)
+	}
+
+	buf := make([]byte, len(data))
+	if n, err := mr.ReadAt(buf, 0); n != len(data) || (err != nil && err != io.EOF) || !bytes.Equal(buf, data) {
+		t.Errorf("读取错误 n=%d err=%v", n, err)
+	}
+	// 并发数量受调度影响，只检查上限且确实并发
+	if m := max.Load(); m > 4 || m <= 1 {
+		t.Errorf("并发数量错误 %d", m)
+	}
+}
+
+func TestMultiReadSeeker(t *testing.T) {
+	data1 := randomutils.RandomBytes(1024 * 1024)
+	data2 := randomutils.RandomBytes(1024*1024 + 100)
+	data := append(append([]byte{}, data1...), data2...)
+
+	mr := ioutils.MultiReadSeeker(bytes.NewReader(data1), bytes.NewReader(nil), bytes.NewReader(data2))
+	if err := iotest.TestSizeReaderAt(mr, data); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestReadSeeker(mr, data); err != nil {
+		t.Error(err)
+	}
+
+	// Part 实际数据少于 Size()
+	short := io.NewSectionReader(bytes.NewReader(data1[:100]), 0, 200)
+	mr = ioutils.MultiReadSeeker(short, bytes.NewReader(data2))
+	var buf [300]byte
+	if n, err := mr.ReadAt(buf[:], 0); n != 100 || err != io.ErrUnexpectedEOF {
+		t.Errorf("未读满应该返回 io.ErrUnexpectedEOF, n=%d err=%v", n, err)
+	}
+}
+
+func TestMutableMultiReaderAt(t *testing.T) {
+	var datas [][]byte
+	for i := 0; i < 4; i++ {
+		datas = append(datas, randomutils.RandomBytes(256*1024))
+	}
+	check := func(mr ioutils.SizeReaderAt, parts ...int) {
+		t.Helper()
+		var want []byte
+		for _, i := range parts {
+			want = append(want, datas[i]...)
+		}
+		if err := iotest.TestSizeReaderAt(mr, want); err != nil {
+			t.Error(err)
+		}
+	}
+
+	mr, err := ioutils.NewMutableMultiReaderAt(bytes.NewReader(datas[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.Append(bytes.NewReader(datas[2]))
+	check(mr, 0, 2)
+
+	mr.Insert(1, bytes.NewReader(datas[1]))
+	check(mr, 0, 1, 2)
+
+	mr.Replace(0, bytes.NewReader(datas[3]))
+	check(mr, 3, 1, 2)
+
+	mr.Remove(1)
+	check(mr, 3, 2)
+
+	if mr.Remove(2) == nil || mr.Insert(3, bytes.NewReader(nil)) == nil {
+		t.Error("超出范围的索引应该返回错误")
+	}
+
+	// 读取与修改并发
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			mr.Append(bytes.NewReader(datas[i%4]))
+		}
+	}()
+	var buf [4096]byte
+	for i := 0; i < 100; i++ {
+		off := int64(randomutils.FastRandn(uint32(mr.Size())))
+		if _, err := mr.ReadAt(buf[:], off); err != nil && err != io.EOF {
+			t.Error(err)
+		}
+	}
+	wg.Wait()
+	if mr.Len() != 102 {
+		t.Errorf("Part数量错误 %d", mr.Len())
+	}
+}
+
+func TestSparseReaderAt(t *testing.T) {
+	data1 := randomutils.RandomBytes(100 * 1024)
+	data2 := randomutils.RandomBytes(300 * 1024)
+	data := make([]byte, 1024*1024)
+	copy(data[200*1024:], data2)
+	copy(data[10*1024:], data1)
+
+	sr, err := ioutils.SparseReaderAt(int64(len(data)),
+		ioutils.SparseExtent{Off: 200 * 1024, R: bytes.NewReader(data2)},
+		ioutils.SparseExtent{Off: 10 * 1024, R: bytes.NewReader(data1)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := iotest.TestSizeReaderAt(sr, data); err != nil {
+		t.Error(err)
+	}
+
+	extents := []ioutils.Range{{10 * 1024, 100 * 1024}, {200 * 1024, 300 * 1024}}
+	holes := []ioutils.Range{{0, 10 * 1024}, {110 * 1024, 90 * 1024}, {500 * 1024, 524 * 1024}}
+	if !reflect.DeepEqual(sr.Extents(), extents) || !reflect.DeepEqual(sr.Holes(), holes) {
+		t.Errorf("范围错误 extents:%v holes:%v", sr.Extents(), sr.Holes())
+	}
+
+	if _, err := ioutils.SparseReaderAt(int64(len(data)),
+		ioutils.SparseExtent{Off: 0, R: bytes.NewReader(data1)},
+		ioutils.SparseExtent{Off: 1024, R: bytes.NewReader(data2)},
+	); err == nil {
+		t.Error("数据段重叠应该返回错误")
+	}
+
+	// 关闭错误的索引为数据段在参数中的索引
+	errClose := errors.New("close error")
+	var order []int
+	sr, err = ioutils.SparseReaderAt(1024,
+		ioutils.SparseExtent{Off: 512, R: &struct {
+			*bytes.Reader
+			io.Closer
+		}{bytes.NewReader(data1[:10]), &testCloser{0, &order, nil}}},
+		ioutils.SparseExtent{Off: 100, R: &struct {
+			*bytes.Reader
+			io.Closer
+		}{bytes.NewReader(data1[:10]), &testCloser{1, &order, errClose}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ce *ioutils.CloseError
+	if err := sr.Close(); !errors.As(err, &ce) || ce.Index != 1 || !reflect.DeepEqual(order, []int{0, 1}) {
+		t.Errorf("关闭错误 err=%v order=%v", err, order)
+	}
+}
+
+func TestMultiCloser(t *testing.T) {
+	var order []int
+	errClose := errors.New("close error")
+	closes := []io.Closer{
+		&testCloser{0, &order, nil},
+		nil,
+		&testCloser{2, &order, errClose},
+	}
+
+	c := ioutils.NewMultiCloser(closes, ioutils.CloseLIFO(true), ioutils.CloseOnce(true), ioutils.SkipNilCloser(true))
+	err := c.Close()
+	var ce *ioutils.CloseError
+	if !errors.As(err, &ce) || ce.Index != 2 || !errors.Is(err, errClose) {
+		t.Errorf("错误信息有误 err=%v", err)
+	}
+	if c.Close() != err || !reflect.DeepEqual(order, []int{2, 0}) {
+		t.Errorf("关闭顺序错误 %v", order)
+	}
+
+	// MultiReaderAt 关闭Part
+	order = nil
+	mr := ioutils.MultiReaderAt(
+		&struct {
+			*bytes.Reader
+			io.Closer
+		}{bytes.NewReader(nil), &testCloser{0, &order, nil}},
+		bytes.NewReader(nil),
+	)
+	if err := mr.Close(); err != nil || !reflect.DeepEqual(order, []int{0}) {
+		t.Errorf("未关闭Part err=%v", err)
+	}
+}
+
+func TestSectionReaderAt(t *testing.T) {
+	data1 := randomutils.RandomBytes(1024 * 1024)
+	data2 := randomutils.RandomBytes(1024 * 1024)
+	data := append(append([]byte{}, data1...), data2...)
+	mr := ioutils.MultiReaderAt(bytes.NewReader(data1), bytes.NewReader(data2))
+
+	// 跨越多个Part
+	sr := ioutils.SectionReaderAt(mr, 512*1024, 1024*1024)
+	want := data[512*1024 : 1536*1024]
+	if err := iotest.TestSizeReaderAt(sr, want); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestReadSeeker(sr, want); err != nil {
+		t.Error(err)
+	}
+
+	// 嵌套截取
+	sr2 := ioutils.SectionReaderAt(sr, 1024, 2*1024*1024)
+	want = data[513*1024 : 1536*1024]
+	if err := iotest.TestSizeReaderAt(sr2, want); err != nil {
+		t.Error(err)
+	}
+
+	// 与缓存组合
+	cr := ioutils.NewReaderAtBuffer(ioutils.SectionReaderAt(mr, 100, 1024*1024), 4096, 12)
+	want = data[100 : 100+1024*1024]
+	if err := iotest.TestSizeReaderAt(cr, want); err != nil {
+		t.Error(err)
+	}
+	sr = ioutils.SectionReaderAt(cr, 4000, 8192)
+	want = want[4000 : 4000+8192]
+	if err := iotest.TestSizeReaderAt(sr, want); err != nil {
+		t.Error(err)
+	}
+
+	// 非法范围
+	sr = ioutils.SectionReaderAt(mr, 512*1024, 1024)
+	var buf [16]byte
+	if _, err := ioutils.SectionReaderAt(sr, -8, 16).ReadAt(buf[:], 0); err != ioutils.ErrNegativeOffset {
+		t.Errorf("嵌套截取负偏移应该返回 ErrNegativeOffset err=%v", err)
+	}
+	if _, err := ioutils.SectionReaderAt(mr, -8, 16).ReadAt(buf[:], 0); err != ioutils.ErrNegativeOffset {
+		t.Errorf("负偏移应该返回 ErrNegativeOffset err=%v", err)
+	}
+	if size := ioutils.SectionReaderAt(sr, 2048, 16).Size(); size != 0 {
+		t.Errorf("超出上层范围 size:%d", size)
+	}
+	if size := ioutils.SectionReaderAt(mr, 0, -1).Size(); size != 0 {
+		t.Errorf("负大小 size:%d", size)
+	}
+
+	// 限制读取大小
+	lr := ioutils.LimitReaderAt(mr, 1536*1024)
+	want = data[:1536*1024]
+	if err := iotest.TestSizeReaderAt(lr, want); err != nil {
 		t.Error(err)
 	}
 }
@@ -37,9 +327,202 @@ func TestMultiReaderAt(t *testing.T) {
 func TestLimtReadSeeker(t *testing.T) {
 	data := randomutils.RandomBytes(2 * 1024 * 1024)
 	r := ioutils.LimitReadSeeker(bytes.NewReader(data), 1024*1024, 1024*1024)
-	if err := testReadSeek(r, 1024*1024, crc32.ChecksumIEEE(data[1024*1024:])); err != nil {
+	if err := iotest.TestReadSeeker(r, data[1024*1024:]); err != nil {
 		t.Error(err)
 	}
+
+	ra, ok := r.(ioutils.ReaderAt)
+	if !ok {
+		t.Fatal("源实现了 io.ReaderAt 但返回值未实现")
+	}
+	if err := iotest.TestReaderAt(ra, data[1024*1024:]); err != nil {
+		t.Error(err)
+	}
+
+	// 不依赖源的当前位置
+	r = ioutils.LimitReadSeeker(struct{ io.ReadSeeker }{bytes.NewReader(data)}, 1024*1024, 1024*1024)
+	if err := iotest.TestReader(r, data[1024*1024:]); err != nil {
+		t.Error(err)
+	}
+
+	// Seek 失败后重新定位
+	fs := &failSeeker{ReadSeeker: bytes.NewReader(data), fail: 1}
+	r = ioutils.LimitReadSeeker(fs, 1024, 1024)
+	var buf [1024]byte
+	if _, err := r.Read(buf[:]); err == nil {
+		t.Error("Seek 失败时应该返回错误")
+	}
+	if n, err := io.ReadFull(r, buf[:]); err != nil || !bytes.Equal(buf[:n], data[1024:2048]) {
+		t.Errorf("重新定位后读取错误 err=%v", err)
+	}
+
+	// Seek 位置错误
+	fs = &failSeeker{ReadSeeker: bytes.NewReader(data), short: true}
+	r = ioutils.LimitReadSeeker(fs, 1024, 1024)
+	if _, err := r.Read(buf[:]); err == nil {
+		t.Error("Seek 位置错误时应该返回错误")
+	}
+}
+
+// 模拟 Seek 失败
+type failSeeker struct {
+	io.ReadSeeker
+	fail  int  // 失败次数
+	short bool // 返回错误的位置
+}
+
+func (f *failSeeker) Seek(offset int64, whence int) (int64, error) {
+	if f.fail > 0 {
+		f.fail--
+		return -1, errors.New("seek error")
+	}
+	n, err := f.ReadSeeker.Seek(offset, whence)
+	if f.short {
+		n--
+	}
+	return n, err
+}
+
+type customSized struct{ n int64 }
+
+func TestGetStreamSize(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "size-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(make([]byte, 1234)); err != nil {
+		t.Fatal(err)
+	}
+
+	ioutils.RegisterStreamSizeResolver(func(r any) (int64, bool) {
+		if v, ok := r.(customSized); ok {
+			return v.n, true
+		}
+		return 0, false
+	}, false)
+
+	seeker := struct{ io.ReadSeeker }{bytes.NewReader(make([]byte, 99))}
+	cases := []struct {
+		name string
+		r    any
+		opts []ioutils.StreamSizeOption
+		size int64
+		ok   bool
+	}{
+		{"Size", strings.NewReader("hello"), nil, 5, true},
+		{"Stat", f, nil, 1234, true},
+		{"Len", bytes.NewBufferString("hello world"), nil, 11, true},
+		{"ContentLength", &http.Response{ContentLength: 42}, nil, 42, true},
+		{"UnknownContentLength", &http.Response{ContentLength: -1}, nil, 0, false},
+		{"Seek", seeker, nil, 99, true},
+		{"NoSideEffect", seeker, []ioutils.StreamSizeOption{ioutils.SetNoSideEffect(true)}, 0, false},
+		{"Resolver", customSized{7}, nil, 7, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			size, err := ioutils.GetStreamSize(tc.r, tc.opts...)
+			if (err == nil) != tc.ok || size != tc.size {
+				t.Fatalf("size=%d err=%v, want size=%d ok=%v", size, err, tc.size, tc.ok)
+			}
+		})
+	}
+}
+
+func TestReaderAtFromSeeker(t *testing.T) {
+	data := randomutils.RandomBytes(2 * 1024 * 1024)
+	rs := bytes.NewReader(data)
+	r := ioutils.ReaderAtFromSeeker(struct{ io.ReadSeeker }{rs}, true)
+
+	if _, err := rs.Seek(100, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := iotest.TestReaderAt(r, data); err != nil {
+		t.Error(err)
+	}
+	if pos, _ := rs.Seek(0, io.SeekCurrent); pos != 100 {
+		t.Fatalf("位置未恢复 pos=%d", pos)
+	}
+
+	if err := iotest.TestConcurrentReadAt(r, data, 8); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	data := randomutils.RandomBytes(1024 * 1024)
+	f, err := os.CreateTemp(t.TempDir(), "adapt-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	// 管道无法获取大小也无法 Seek
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		pw.Write(data)
+		pw.Close()
+	}()
+
+	cases := []struct {
+		name     string
+		r        any
+		opts     []ioutils.AdaptOption
+		strategy ioutils.AdaptStrategy
+	}{
+		{"native", ioutils.MultiReadSeeker(bytes.NewReader(data[:1000]), bytes.NewReader(data[1000:])), nil, ioutils.AdaptNative},
+		{"file", f, nil, ioutils.AdaptAddSize},
+		{"readerAt", struct{ io.ReaderAt }{bytes.NewReader(data)}, []ioutils.AdaptOption{ioutils.SetAdaptSize(int64(len(data)))}, ioutils.AdaptSection},
+		{"readSeeker", struct{ io.ReadSeeker }{bytes.NewReader(data)}, nil, ioutils.AdaptSeekerBuffer},
+		{"readSeekerUnbuffered", struct{ io.ReadSeeker }{bytes.NewReader(data)}, []ioutils.AdaptOption{ioutils.SetAdaptUnbuffered(true)}, ioutils.AdaptSeeker},
+		{"readSeekerBuffer", struct{ io.ReadSeeker }{bytes.NewReader(data)}, []ioutils.AdaptOption{ioutils.SetAdaptBlock(64*1024, 8)}, ioutils.AdaptSeekerBuffer},
+		{"reader", struct{ io.Reader }{bytes.NewReader(data)}, nil, ioutils.AdaptReaderBuffer},
+		{"pipe", pr, nil, ioutils.AdaptReaderBuffer},
+		{"seekFailed", struct{ io.ReadSeeker }{&failSeeker{ReadSeeker: bytes.NewReader(data), fail: math.MaxInt}}, nil, ioutils.AdaptReaderBuffer},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, strategy, err := ioutils.Adapt(tc.r, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			if strategy != tc.strategy {
+				t.Fatalf("strategy=%s, want %s", strategy, tc.strategy)
+			}
+			if r.Size() != int64(len(data)) {
+				t.Fatalf("Size()=%d", r.Size())
+			}
+			if err := iotest.TestReaderAt(r, data); err != nil {
+				t.Error(err)
+			}
+			if err := iotest.TestReadSeeker(r, data); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	// 无法获取大小
+	if _, _, err := ioutils.Adapt(struct{ io.ReaderAt }{bytes.NewReader(data)}); err == nil {
+		t.Fatal("ReaderAt 无法获取大小时应返回错误")
+	}
+	unknown := ioutils.NewBufferReadSeeker(&failSeeker{ReadSeeker: bytes.NewReader(data), fail: 1}, 4096, 4)
+	if unknown.Size() >= 0 {
+		t.Fatalf("Size()=%d", unknown.Size())
+	}
+	r, strategy, err := ioutils.Adapt(unknown, ioutils.SetAdaptSize(int64(len(data))))
+	if err != nil || strategy != ioutils.AdaptAddSize || r.Size() != int64(len(data)) {
+		t.Fatalf("指定大小后应补充 Size strategy=%s err=%v", strategy, err)
+	}
+	// 大小未知时不直接返回，经 NewBufferReader 获取大小
+	r, strategy, err = ioutils.Adapt(unknown)
+	if err != nil || strategy != ioutils.AdaptReaderBuffer || r.Size() != int64(len(data)) {
+		t.Fatalf("大小未知时应经 NewBufferReader 缓存 strategy=%s err=%v", strategy, err)
+	}
 }
 
 func TestCrossReader(t *testing.T) {
@@ -47,220 +530,741 @@ func TestCrossReader(t *testing.T) {
 	data2 := randomutils.RandomBytes(2 * 1024 * 1024)
 	r := ioutils.CrossReader(bytes.NewReader(data1), bytes.NewReader(data2), 1024*1024, 1024*1024)
 
-	hash := crc32.NewIEEE()
-	hash.Write(data1[:1024*1024])
-	hash.Write(data2[:1024*1024])
-	hash.Write(data1[1024*1024:])
-	hash.Write(data2[1024*1024:])
+	want := bytes.Join([][]byte{data1[:1024*1024], data2[:1024*1024], data1[1024*1024:], data2[1024*1024:]}, nil)
+	if err := iotest.TestReader(r, want); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInterleaveReader(t *testing.T) {
+	a := []byte("aaaaaa")
+	b := []byte("bbbbbbbbbbbb")
+	c := []byte("cc")
+
+	cases := []struct {
+		name   string
+		policy ioutils.InterleavePolicy
+		want   string
+	}{
+		{"stop", ioutils.InterleaveStop, "aaaabbbccaa"},
+		{"skip", ioutils.InterleaveSkip, "aaaabbbccaabbbbbbbbb"},
+		{"pad", ioutils.InterleavePad, "aaaabbbccaaxxbbb--xxxxbbb--xxxxbbb"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := ioutils.InterleaveReader(
+				ioutils.InterleavePart{R: bytes.NewReader(a), Stride: 4, OnEOF: tc.policy, Filler: bytes.NewReader(bytes.Repeat([]byte("x"), 64))},
+				ioutils.InterleavePart{R: bytes.NewReader(b), Stride: 3, OnEOF: ioutils.InterleaveSkip},
+				ioutils.InterleavePart{R: stdiotest.OneByteReader(bytes.NewReader(c)), Stride: 2, OnEOF: tc.policy, Filler: bytes.NewReader(bytes.Repeat([]byte("-"), 64))},
+			)
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeinterleaveWriter(t *testing.T) {
+	data1 := randomutils.RandomBytes(3 * 1024)
+	data2 := randomutils.RandomBytes(5 * 1024)
+	data3 := randomutils.RandomBytes(1 * 1024)
+
+	var buf1, buf2, buf3 bytes.Buffer
+	w := ioutils.DeinterleaveWriter(
+		ioutils.DeinterleavePart{W: &buf1, Stride: 3},
+		ioutils.DeinterleavePart{W: &buf2, Stride: 5},
+		ioutils.DeinterleavePart{W: &buf3, Stride: 1},
+	)
+	r := ioutils.InterleaveReader(
+		ioutils.InterleavePart{R: bytes.NewReader(data1), Stride: 3},
+		ioutils.InterleavePart{R: bytes.NewReader(data2), Stride: 5},
+		ioutils.InterleavePart{R: bytes.NewReader(data3), Stride: 1},
+	)
+	if _, err := io.CopyBuffer(w, r, make([]byte, 7)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf1.Bytes(), data1) || !bytes.Equal(buf2.Bytes(), data2) || !bytes.Equal(buf3.Bytes(), data3) {
+		t.Fatal("DeinterleaveWriter 结果有误")
+	}
+}
+
+func TestRepeatReader(t *testing.T) {
+	buf := make([]byte, 7)
+	if _, err := io.ReadFull(ioutils.NewRepeatReader('a', 'b', 'c'), buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "abcabca" {
+		t.Fatalf("got %q", buf)
+	}
+
+	if _, err := ioutils.NewRepeatReader().Read(buf); err != io.EOF {
+		t.Fatalf("空数据应返回 io.EOF, err=%v", err)
+	}
+}
 
-	if err := testReader(r, hash.Sum32()); err != nil {
+func TestRepeatReaderN(t *testing.T) {
+	pattern := randomutils.RandomBytes(1000)
+	data := bytes.Repeat(pattern, 2100)[:2*1024*1024]
+	r := ioutils.RepeatReaderN(pattern, int64(len(data)))
+
+	if r.Size() != int64(len(data)) {
+		t.Fatalf("Size()=%d, want %d", r.Size(), len(data))
+	}
+	if err := iotest.TestReaderAt(r, data); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestReadSeeker(r, data); err != nil {
 		t.Error(err)
 	}
+
+	if r := ioutils.RepeatReaderN(nil, 100); r.Size() != 0 {
+		t.Fatalf("空数据 Size()=%d", r.Size())
+	}
+}
+
+func TestZeroReaderAt(t *testing.T) {
+	data := make([]byte, 2*1024*1024+123)
+	r := ioutils.ZeroReaderAt(int64(len(data)))
+
+	if err := iotest.TestReaderAt(r, data); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestReadSeeker(r, data); err != nil {
+		t.Error(err)
+	}
+
+	// WriteTo
+	if _, err := r.Seek(100, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	hash := crc32.NewIEEE()
+	n, err := io.Copy(hash, r)
+	if err != nil || n != int64(len(data)-100) || hash.Sum32() != crc32.ChecksumIEEE(data[100:]) {
+		t.Fatalf("WriteTo 结果有误 n=%d err=%v", n, err)
+	}
 }
 
 func TestBufferingReader(t *testing.T) {
 	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
 	r := ioutils.NewBufferReader(bytes.NewReader(data1))
 
-	if err := testReadAt(r, int64(len(data1)), crc32.ChecksumIEEE(data1)); err != nil {
+	if err := iotest.TestReaderAt(r, data1); err != nil {
 		t.Error(err)
 	}
 
-	if err := testReadSeek(r, int64(len(data1)), crc32.ChecksumIEEE(data1)); err != nil {
+	if err := iotest.TestReadSeeker(r, data1); err != nil {
 		t.Error(err)
 	}
-}
 
-func TestReaderAtBuffer(t *testing.T) {
-	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
-	r := ioutils.NewReaderAtBuffer(bytes.NewReader(data1), 4096, 12)
-	if err := testReadAt(r, int64(len(data1)), crc32.ChecksumIEEE(data1)); err != nil {
+	if err := iotest.TestClose(r); err != nil {
 		t.Error(err)
 	}
 }
 
-func TestBufferReadSeeker(t *testing.T) {
+func TestBufferingReaderSpill(t *testing.T) {
+	dir := t.TempDir()
 	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
-	r := ioutils.NewBufferReadSeeker(bytes.NewReader(data1), 4096, 12)
-	if err := testReadAt(r, int64(len(data1)), crc32.ChecksumIEEE(data1)); err != nil {
+	r := ioutils.NewBufferReader(bytes.NewReader(data1), ioutils.SetSpill(64*1024, dir))
+
+	// 后台写入临时文件时并发读取
+	if err := iotest.TestConcurrentReadAt(r, data1, 8); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestReaderAt(r, data1); err != nil {
 		t.Error(err)
 	}
 
-	if err := testReadSeek(r, int64(len(data1)), crc32.ChecksumIEEE(data1)); err != nil {
+	if err := iotest.TestReadSeeker(r, data1); err != nil {
 		t.Error(err)
 	}
-}
 
-func TestHttpReader(t *testing.T) {
-	file, err := os.CreateTemp("", "iotest-*")
-	if err != nil {
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("临时文件数量错误 %d", len(files))
+	}
+	if err := r.Close(); err != nil {
 		t.Error(err)
-		return
 	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Error("关闭后未删除临时文件")
+	}
+}
+
+func TestBufferingReaderWindow(t *testing.T) {
 	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
-	file.Write(data1)
-	file.Close()
+	r := ioutils.NewBufferReader(bytes.NewReader(data1), ioutils.SetWindow(64*1024))
 
-	defer os.RemoveAll(file.Name())
+	if err := iotest.TestReader(r, data1); err != nil {
+		t.Error(err)
+	}
 
-	ht := &http.Transport{}
-	ht.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
-	hc := &http.Client{Transport: ht}
+	var buf [4096]byte
+	if _, err := r.ReadAt(buf[:], 0); err != ioutils.ErrOutsideWindow {
+		t.Errorf("读取窗口之前的数据应该返回 ErrOutsideWindow, err=%v", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != ioutils.ErrOutsideWindow {
+		t.Errorf("Seek 到窗口之前应该返回 ErrOutsideWindow, err=%v", err)
+	}
 
-	r, err := http_reader.NewHttpReader(http.MethodGet, "file://"+file.Name(), http_reader.SetClient(hc))
-	if err != nil {
-		t.Error(err)
-		return
+	// 窗口内可回退读取
+	off := int64(len(data1) - 64*1024)
+	n, err := r.ReadAt(buf[:], off)
+	if (err != nil && err != io.EOF) || !bytes.Equal(buf[:n], data1[off:off+int64(n)]) {
+		t.Errorf("读取窗口内数据错误 err=%v", err)
 	}
+
+	// 无界数据流
+	r = ioutils.NewBufferReader(io.LimitReader(ioutils.Zero, 16*1024*1024), ioutils.SetWindow(64*1024))
+	if n, err := r.Seek(-10, io.SeekEnd); err != nil || n != 16*1024*1024-10 {
+		t.Errorf("SeekEnd 错误 n=%d err=%v", n, err)
+	}
+}
+
+func TestBufferingReaderWindowMemory(t *testing.T) {
+	// 跳过大量数据时内存占用不超过窗口与单次读取大小之和，释放的分块可复用
+	allocated := func(fn func()) uint64 {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		fn()
+		runtime.ReadMemStats(&after)
+		return after.TotalAlloc - before.TotalAlloc
+	}
+
+	r := ioutils.NewBufferReader(io.LimitReader(ioutils.Zero, 256<<20), ioutils.SetWindow(64*1024))
 	defer r.Close()
+	var buf [16]byte
+	if n := allocated(func() {
+		if _, err := r.ReadAt(buf[:], 128<<20); err != nil {
+			t.Error(err)
+		}
+	}); n > 8<<20 {
+		t.Errorf("ReadAt 内存占用过多 %d", n)
+	}
+	if n := allocated(func() {
+		if _, err := r.Seek(192<<20, io.SeekCurrent); err != nil {
+			t.Error(err)
+		}
+	}); n > 8<<20 {
+		t.Errorf("Seek 内存占用过多 %d", n)
+	}
+}
+
+func TestBufferingReaderConcurrent(t *testing.T) {
+	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
+	r := ioutils.NewBufferReader(bytes.NewReader(data1))
+	defer r.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := iotest.TestReaderAt(r, data1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 等待后续数据时，已缓存部分的读取不被阻塞
+	pr, pw := io.Pipe()
+	r = ioutils.NewBufferReader(pr)
+	defer r.Close()
+	go pw.Write(data1[:4096])
+
+	var buf [4096]byte
+	if _, err := r.ReadAt(buf[:], 0); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := r.ReadAt(buf[:], 8192)
+		done <- err
+	}()
+
+	res := make(chan error)
+	go func() {
+		var buf [1024]byte
+		_, err := r.ReadAt(buf[:], 1024)
+		res <- err
+	}()
+	select {
+	case err := <-res:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("读取已缓存部分被阻塞")
+	}
 
-	if err := testSizeReadAt(r, int64(len(data1)), crc32.ChecksumIEEE(data1)); err != nil {
+	pw.Write(data1[4096 : 3*4096])
+	if err := <-done; err != nil {
 		t.Error(err)
 	}
+	if !bytes.Equal(buf[:], data1[8192:3*4096]) {
+		t.Error("读取内容错误")
+	}
+	pw.Close()
+}
+
+func TestBroadcast(t *testing.T) {
+	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
+	b := ioutils.Broadcast(bytes.NewReader(data1))
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		r := b.NewReader()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer r.Close()
+
+			// 不同速度读取
+			hash := crc32.NewIEEE()
+			buf := make([]byte, 1024<<(i*3))
+			if _, err := io.CopyBuffer(hash, struct{ io.Reader }{r}, buf); err != nil {
+				t.Error(err)
+			}
+			if hash.Sum32() != crc32.ChecksumIEEE(data1) {
+				t.Error("读取内容错误")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 释放所有读取者都已读过的数据
+	b = ioutils.Broadcast(bytes.NewReader(data1))
+	defer b.Close()
+	r1, r2 := b.NewReader(), b.NewReader()
+	io.Copy(io.Discard, r1)
+	io.CopyN(io.Discard, r2, 1024*1024)
 
-	if err := testReadSeek(r, int64(len(data1)), crc32.ChecksumIEEE(data1)); err != nil {
+	var buf [4096]byte
+	r3 := b.NewReader()
+	if n, _ := io.ReadFull(r3, buf[:]); !bytes.Equal(buf[:n], data1[1024*1024:1024*1024+4096]) {
+		t.Error("新的读取者应从最慢读取者的位置开始")
+	}
+}
+
+func TestReaderAtBuffer(t *testing.T) {
+	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
+	r := ioutils.NewReaderAtBuffer(bytes.NewReader(data1), 4096, 12)
+	if err := iotest.TestReaderAt(r, data1); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestConcurrentReadAt(r, data1, 8); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestConcurrentReadAt(r, data1, 8); err != nil {
 		t.Error(err)
 	}
 }
 
-func testSizeReadAt(rs ioutils.SizeReaderAt, size int64, crc32_ uint32) error {
-	if rs.Size() != size {
-		return errors.Errorf("大小错误,实际大小:%d != %d", rs.Size(), size)
+func TestReaderAtBufferSize(t *testing.T) {
+	data := randomutils.RandomBytes(10*4096 + 100)
+	cr := &countReaderAt{r: bytes.NewReader(data)}
+	r := ioutils.NewReaderAtBuffer(&sizeCountReaderAt{cr, int64(len(data))}, 4096, 12)
+
+	if err := iotest.TestSizeReaderAt(r, data); err != nil {
+		t.Error(err)
+	}
+
+	// 读取末尾时与数据一同返回 io.EOF
+	var buf [200]byte
+	n, err := r.ReadAt(buf[:], int64(len(data)-100))
+	if n != 100 || err != io.EOF || !bytes.Equal(buf[:n], data[len(data)-100:]) {
+		t.Errorf("读取末尾错误 n=%d err=%v", n, err)
+	}
+
+	// 超出末尾不再读取底层
+	count := cr.count.Load()
+	if n, err := r.ReadAt(buf[:], int64(len(data))); n != 0 || err != io.EOF {
+		t.Errorf("超出末尾读取错误 n=%d err=%v", n, err)
+	}
+	if cr.count.Load() != count {
+		t.Error("超出末尾时读取了底层")
 	}
-	return testReadAt(rs, rs.Size(), crc32_)
 }
 
-func testReadAt(r ioutils.ReaderAt, size int64, crc32_ uint32) error {
-	var (
-		n   int
-		err error
-		buf [4096]byte
+func TestReaderAtBufferRevalidate(t *testing.T) {
+	data1 := randomutils.RandomBytes(16 * 4096)
+	data2 := randomutils.RandomBytes(16 * 4096)
+	src := bytes.NewReader(data1)
+	cr := &countReaderAt{r: src}
+
+	var changed bool
+	r := ioutils.NewReaderAtBuffer(cr, 4096, 32,
+		ioutils.SetBlockTTL(10*time.Millisecond),
+		ioutils.SetRevalidate(func() (bool, error) { return changed, nil }),
 	)
 
-	/* 正常功能测试 */
-	for i := 0; i < int(systemutil.Log(size)); i++ {
-		off := randomutils.FastRandn(uint32(size))
-		n, err = r.ReadAt(buf[:], int64(off))
+	var buf [4096]byte
+	r.ReadAt(buf[:], 0)
+	src.Reset(data2)
 
-		if n < 0 || int64(n) > size || n > len(buf) {
-			return errors.Errorf("n 超过正常范围")
-		}
+	// 未过期，使用缓存
+	if r.ReadAt(buf[:], 0); !bytes.Equal(buf[:], data1[:4096]) {
+		t.Error("缓存块未过期但已失效")
+	}
 
-		lb := len(buf)
-		// n != len(p) 必然返回错误
-		if n != lb && err == nil {
-			return errors.Errorf("ReadAt实现有误, (n=%d) != (len(p)=%d) 但 err=nil", n, lb)
-		}
+	// 过期但数据源未变化，续期
+	time.Sleep(20 * time.Millisecond)
+	count := cr.count.Load()
+	if r.ReadAt(buf[:], 0); !bytes.Equal(buf[:], data1[:4096]) || cr.count.Load() != count {
+		t.Error("数据源未变化但缓存块已失效")
+	}
 
-		// n == len(buf) 必须返回io.EOF或nil
-		if n == lb && err != nil && err != io.EOF {
-			return errors.Errorf("buf 已读满，但返回非法错误%s", err)
-		}
+	// 过期且数据源变化，清空缓存
+	time.Sleep(20 * time.Millisecond)
+	changed = true
+	if r.ReadAt(buf[:], 0); !bytes.Equal(buf[:], data2[:4096]) {
+		t.Error("数据源已变化但缓存块未失效")
 	}
+}
 
-	/* 非法值测试 */
-	n, err = r.ReadAt(buf[:], size+1)
-	if n != 0 || err == nil {
-		return errors.Errorf("超过范围的读取应该返回错误, 并且保证 n==0")
+func TestReaderAtBufferGrow(t *testing.T) {
+	data := randomutils.RandomBytes(200)
+	src := bytes.NewReader(data[:100])
+	r := ioutils.NewReaderAtBuffer(src, 64, 8, ioutils.SetBlockTTL(10*time.Millisecond))
+
+	var buf [16]byte
+	if n, err := r.ReadAt(buf[:], 90); n != 10 || err != io.EOF {
+		t.Fatalf("读取末尾错误 n=%d err=%v", n, err)
 	}
 
-	n, err = r.ReadAt(buf[:], -1)
-	if n != 0 || err == nil {
-		return errors.Errorf("负数偏移的读取应该返回错误, 并且保证 n==0")
+	// 数据源增长，过期后读取新增的数据
+	src.Reset(data)
+	time.Sleep(20 * time.Millisecond)
+	if n, err := r.ReadAt(buf[:], 120); n != len(buf) || err != nil || !bytes.Equal(buf[:], data[120:136]) {
+		t.Errorf("未读取到新增的数据 n=%d err=%v", n, err)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Errorf("大小未更新 Size()=%d", r.Size())
+	}
+	// 之前缓存的末尾块不完整，需要重新加载
+	if n, err := r.ReadAt(buf[:], 90); n != len(buf) || err != nil || !bytes.Equal(buf[:], data[90:106]) {
+		t.Errorf("末尾块未重新加载 n=%d err=%v", n, err)
 	}
+}
 
-	/* 内容测试 */
-	hash := crc32.NewIEEE()
-	for off := int64(0); off < size; off += int64(n) {
-		n, err = r.ReadAt(buf[:randomutils.FastRandn(4096)], off)
-		hash.Write(buf[:n])
-		if err != nil {
-			if err != io.EOF {
-				return errors.WithMessage(err, "内容测试读取错误")
+func TestReaderAtBufferConcurrentRevalidate(t *testing.T) {
+	data1 := randomutils.RandomBytes(4 * 4096)
+	data2 := randomutils.RandomBytes(4 * 4096)
+	src := bytes.NewReader(data1)
+
+	var calls atomic.Int32
+	r := ioutils.NewReaderAtBuffer(src, 4096, 8,
+		ioutils.SetBlockTTL(50*time.Millisecond),
+		ioutils.SetRevalidate(func() (bool, error) {
+			calls.Add(1)
+			time.Sleep(5 * time.Millisecond)
+			return true, nil
+		}),
+	)
+
+	var buf [4096]byte
+	r.ReadAt(buf[:], 0)
+	src.Reset(data2)
+	time.Sleep(60 * time.Millisecond)
+
+	// 同时发现过期时只校验一次，且都能读取到新数据
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf [4096]byte
+			if r.ReadAt(buf[:], 0); !bytes.Equal(buf[:], data2[:4096]) {
+				t.Error("数据源已变化但缓存块未失效")
 			}
-			break
-		}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("校验次数 %d != 1", calls.Load())
+	}
+}
+
+func TestBlockCache(t *testing.T) {
+	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
+	data2 := randomutils.RandomBytes(1024 * 1024)
+
+	cache := ioutils.NewBlockCache(4096, 16*4096)
+	r1 := cache.NewReaderAt(bytes.NewReader(data1))
+	defer r1.Close()
+	cr := &countReaderAt{r: bytes.NewReader(data2)}
+	r2 := cache.NewReaderAt(cr)
+	defer r2.Close()
+
+	if err := iotest.TestReaderAt(r1, data1); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestReaderAt(r2, data2); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestConcurrentReadAt(r1, data1, 8); err != nil {
+		t.Error(err)
+	}
+	if err := iotest.TestConcurrentReadAt(r1, data1, 8); err != nil {
+		t.Error(err)
+	}
+	if cache.Len() > cache.Cap() {
+		t.Errorf("缓存块数量超过上限 %d > %d", cache.Len(), cache.Cap())
 	}
 
-	crc32_2 := hash.Sum32()
-	if crc32_2 != crc32_ {
-		return errors.Errorf("读取内容错误, 内容crc32不匹配 %d != %d", crc32_2, crc32_)
+	// 优先淘汰占用最多的读取器
+	var buf [4 * 4096]byte
+	r2.ReadAt(buf[:], 0)
+	io.Copy(io.Discard, io.NewSectionReader(r1, 0, int64(len(data1))))
+	count := cr.count.Load()
+	if _, err := r2.ReadAt(buf[:], 0); err != nil {
+		t.Error(err)
+	}
+	if cr.count.Load() != count {
+		t.Error("缓存块被不公平淘汰")
 	}
 
-	return nil
+	r1.Close()
+	r2.Close()
+	if cache.Len() != 0 {
+		t.Errorf("关闭后未释放缓存块, 剩余 %d", cache.Len())
+	}
 }
 
-func testReadSeek(r ioutils.ReadSeeker, size int64, crc32_ uint32) error {
-	n, err := ioutils.StreamSizeBySeeking(r, true)
+func TestReadWriterAtBuffer(t *testing.T) {
+	file, err := os.CreateTemp("", "iotest-*")
 	if err != nil {
-		return errors.WithMessage(err, "StreamSizeBySeeking")
-	}
-	if n != size {
-		return errors.New("seek 获取大小与数据大小不符合")
+		t.Error(err)
+		return
 	}
+	defer os.RemoveAll(file.Name())
 
-	/* 正常使用测试 */
-	var buf [4096]byte
+	data := randomutils.RandomBytes(1024 * 1024)
+	file.Write(data)
 
-	// Seek可用性测试
-	for i := 0; i < int(systemutil.Log(size)); i++ {
-		off := int64(randomutils.FastRandn(uint32(size)))
-		noff, err := r.Seek(off, io.SeekStart)
-		if err != nil || noff != off {
-			return errors.Errorf("Seek错误 noff:%d != off:%d, err=%s", noff, off, err)
+	r := ioutils.NewReadWriterAtBuffer(file, 4096, 12)
+	for i := 0; i < 1000; i++ {
+		// 随机小写入，部分超出原始大小
+		off := int64(randomutils.FastRandn(uint32(len(data) + 8192)))
+		p := randomutils.RandomBytes(int(randomutils.FastRandn(8192)))
+		if _, err := r.WriteAt(p, off); err != nil {
+			t.Error(err)
+			return
 		}
-	}
-	for i := 0; i < int(systemutil.Log(size)); i++ {
-		off := -int64(randomutils.FastRandn(uint32(size)))
-		noff, err := r.Seek(off, io.SeekEnd)
-		off = size + off
-		if err != nil || noff != off {
-			return errors.Errorf("Seek错误 noff:%d != off:%d, err=%s", noff, off, err)
+		if end := int(off) + len(p); end > len(data) {
+			data = append(data, make([]byte, end-len(data))...)
 		}
+		copy(data[off:], p)
+	}
+
+	if err := iotest.TestReaderAt(r, data); err != nil {
+		t.Error(err)
 	}
 
-	// 读文件头
-	if _, err := r.Seek(0, io.SeekStart); err != nil {
-		return err
+	if err := r.Close(); err != nil {
+		t.Error(err)
+		return
 	}
-	if _, err := r.Read(buf[:]); err != nil && err != io.EOF {
-		return err
+	data2, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(data, data2) {
+		t.Error("写回内容错误")
 	}
+}
 
-	// 读文件末尾
-	if _, err := r.Seek(0, io.SeekEnd); err != nil {
-		return err
+func TestBufferReadSeeker(t *testing.T) {
+	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
+	r := ioutils.NewBufferReadSeeker(bytes.NewReader(data1), 4096, 12)
+	if err := iotest.TestReaderAt(r, data1); err != nil {
+		t.Error(err)
 	}
-	if _, err := r.Read(buf[:]); err != io.EOF {
-		return errors.WithMessage(err, "读取文件末尾应该返回io.EOF")
+	if err := iotest.TestConcurrentReadAt(r, data1, 8); err != nil {
+		t.Error(err)
 	}
 
-	/* 非法值测试 */
-	if _, err := r.Seek(-1, io.SeekStart); err == nil {
-		return errors.Errorf("SeekStart 错误的范围，应该返回错误")
+	if err := iotest.TestReadSeeker(r, data1); err != nil {
+		t.Error(err)
 	}
 
-	if _, err := r.Seek(-size-1, io.SeekCurrent); err == nil {
-		return errors.Errorf("SeekCurrent 错误的范围，应该返回错误")
+	if err := iotest.TestConcurrentReadAt(r, data1, 8); err != nil {
+		t.Error(err)
 	}
+}
+
+func TestBufferReadSeekerPosition(t *testing.T) {
+	data := randomutils.RandomBytes(64*1024 + 10)
+	// 隐藏 Size 方法，通过 Seek 获取大小
+	r := ioutils.NewBufferReadSeeker(struct{ io.ReadSeeker }{bytes.NewReader(data)}, 4096, 4)
 
-	if _, err := r.Seek(-size-1, io.SeekEnd); err == nil {
-		return errors.Errorf("SeekEnd 错误的范围，应该返回错误")
+	var (
+		buf [1000]byte
+		pos int64
+	)
+	for i := 0; i < 1000; i++ {
+		switch randomutils.FastRandn(3) {
+		case 0:
+			n, err := r.Read(buf[:randomutils.FastRandn(1000)])
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf[:n], data[pos:pos+int64(n)]) {
+				t.Fatalf("Read 内容错误 pos:%d", pos)
+			}
+			pos += int64(n)
+		case 1:
+			off := int64(randomutils.FastRandn(uint32(len(data))))
+			n, err := r.ReadAt(buf[:], off)
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf[:n], data[off:off+int64(n)]) {
+				t.Fatalf("ReadAt 内容错误 off:%d", off)
+			}
+		case 2:
+			off := int64(randomutils.FastRandn(uint32(len(data) + 1)))
+			noff, err := r.Seek(off-pos, io.SeekCurrent)
+			if err != nil || noff != off {
+				t.Fatalf("Seek错误 noff:%d != off:%d, err=%s", noff, off, err)
+			}
+			pos = off
+		}
+
+		if cur, err := r.Seek(0, io.SeekCurrent); err != nil || cur != pos {
+			t.Fatalf("位置错误 cur:%d != pos:%d, err=%s", cur, pos, err)
+		}
 	}
 
-	r.Seek(0, io.SeekStart)
-	return testReader(r, crc32_)
+	if _, err := r.Seek(1, io.SeekEnd); err == nil {
+		t.Error("超出末尾的 Seek 应该返回错误")
+	}
 }
 
-func testReader(r ioutils.Reader, crc32_ uint32) error {
-	hash := crc32.NewIEEE()
-	_, err := io.Copy(hash, r)
-	if err != nil && err != io.EOF {
-		return err
+func TestHttpReader(t *testing.T) {
+	file, err := os.CreateTemp("", "iotest-*")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data1 := randomutils.RandomBytes(2 * 1024 * 1024)
+	file.Write(data1)
+	file.Close()
+
+	defer os.RemoveAll(file.Name())
+
+	ht := &http.Transport{}
+	ht.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	hc := &http.Client{Transport: ht}
+
+	r, err := http_reader.NewHttpReader(http.MethodGet, "file://"+file.Name(), http_reader.SetClient(hc))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer r.Close()
+
+	if err := iotest.TestSizeReaderAt(r, data1); err != nil {
+		t.Error(err)
+	}
+
+	if err := iotest.TestReadSeeker(r, data1); err != nil {
+		t.Error(err)
+	}
+}
+
+// 统计底层读取次数
+type countReaderAt struct {
+	r     io.ReaderAt
+	count atomic.Int32
+}
+
+func (c *countReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.count.Add(1)
+	return c.r.ReadAt(p, off)
+}
+
+type sizeCountReaderAt struct {
+	*countReaderAt
+	size int64
+}
+
+func (c *sizeCountReaderAt) Size() int64 { return c.size }
+
+func BenchmarkBufferReader(b *testing.B) {
+	data := randomutils.RandomBytes(16 * 1024 * 1024)
+
+	b.Run("Chunk", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			r := ioutils.NewBufferReader(bytes.NewReader(data))
+			io.Copy(io.Discard, r)
+			r.Close()
+		}
+	})
+
+	b.Run("Append", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			io.Copy(io.Discard, &appendBufferReader{r: bytes.NewReader(data)})
+		}
+	})
+}
+
+// 分块存储之前的实现，用于性能对比
+type appendBufferReader struct {
+	r      io.Reader
+	buf    []byte
+	offset int64
+	eof    bool
+}
+
+func (br *appendBufferReader) Read(p []byte) (n int, err error) {
+	if need := br.offset + int64(len(p)) - int64(len(br.buf)); need > 0 && !br.eof {
+		buf := make([]byte, need)
+		rn, err := io.ReadFull(br.r, buf)
+		if err != nil {
+			br.eof = true
+		}
+		br.buf = append(br.buf, buf[:rn]...)
+	}
+	if br.offset >= int64(len(br.buf)) {
+		return 0, io.EOF
 	}
+	n = copy(p, br.buf[br.offset:])
+	br.offset += int64(n)
+	return
+}
 
-	if hash.Sum32() != crc32_ {
-		return errors.Errorf("读取内容错误, 内容crc32不匹配")
+// 模拟远程读取，统计最大并发数量
+type slowReaderAt struct {
+	*bytes.Reader
+	running *atomic.Int32
+	max     *atomic.Int32
+}
+
+func (r *slowReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := r.running.Add(1)
+	defer r.running.Add(-1)
+	for m := r.max.Load(); n > m && !r.max.CompareAndSwap(m, n); m = r.max.Load() {
 	}
-	return nil
+	time.Sleep(time.Millisecond)
+	return r.Reader.ReadAt(p, off)
+}
+
+type testCloser struct {
+	index int
+	order *[]int
+	err   error
+}
+
+func (c *testCloser) Close() error {
+	*c.order = append(*c.order, c.index)
+	return c.err
 }
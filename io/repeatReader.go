@@ -12,17 +12,80 @@ func (r *repeatReader) Reset() {
 }
 
 func (r *repeatReader) Read(p []byte) (n int, err error) {
-	if r.off >= len(r.data) {
-		r.Reset()
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	for n < len(p) {
+		if r.off >= len(r.data) {
+			r.Reset()
+		}
+		c := copy(p[n:], r.data[r.off:])
+		r.off += c
+		n += c
 	}
-	n = copy(p, r.data[r.off:])
 	return
 }
 
 // RepeatReader
-// 重复读取data数据，永远不会返回io.EOF
+// 重复读取data数据，data为空时返回 io.EOF，否则永远不会返回 io.EOF
 func NewRepeatReader(data ...byte) io.Reader {
 	return &repeatReader{
 		data: data,
 	}
 }
+
+// RepeatReaderN
+// 重复data数据，共total字节
+// 任意位置的数据由data计算得出，可用于构造测试文件
+// data为空时大小为0
+func RepeatReaderN(data []byte, total int64) SizeReadSeekReaderAt {
+	if len(data) == 0 || total < 0 {
+		total = 0
+	}
+	return &repeatReaderN{data: data, size: total}
+}
+
+type repeatReaderN struct {
+	data []byte
+	size int64
+	off  int64 // Read 位置
+}
+
+func (r *repeatReaderN) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	if remain := r.size - off; remain < int64(len(p)) {
+		p, err = p[:remain], io.EOF
+	}
+	pos := int(off % int64(len(r.data)))
+	for n < len(p) {
+		c := copy(p[n:], r.data[pos:])
+		n += c
+		pos = 0
+	}
+	return n, err
+}
+
+func (r *repeatReaderN) Read(p []byte) (n int, err error) {
+	n, err = r.ReadAt(p, r.off)
+	r.off += int64(n)
+	return
+}
+
+func (r *repeatReaderN) Seek(offset int64, whence int) (int64, error) {
+	off, err := resolveSeek(offset, whence, r.off, r.size)
+	if err != nil {
+		return r.off, err
+	}
+	r.off = off
+	return r.off, nil
+}
+
+func (r *repeatReaderN) Size() int64 {
+	return r.size
+}
@@ -0,0 +1,72 @@
+package ioutils
+
+import (
+	"io"
+
+	systemutil "github.com/foxxorcat/library-go/system"
+)
+
+// SectionReaderAt
+// 截取 ReaderAt 的 [off, off+size) 部分
+// 仅通过 ReadAt 访问r，多个截取可并发共用同一个r
+// size < 0 视为 0，off < 0 时读取返回 ErrNegativeOffset
+func SectionReaderAt(r ReaderAt, off, size int64) SizeReadSeekReaderAt {
+	size = systemutil.Max(size, 0)
+	// 嵌套截取时直接访问底层，范围限制在上层之内
+	if s, ok := r.(*sectionReaderAt); ok && off >= 0 {
+		off = systemutil.Min(off, s.size)
+		size = systemutil.Min(size, s.size-off)
+		r, off = s.r, s.base+off
+	}
+	return &sectionReaderAt{r: r, base: off, size: size}
+}
+
+// LimitReaderAt
+// 仅读取 ReaderAt 的前 n 字节
+func LimitReaderAt(r ReaderAt, n int64) SizeReadSeekReaderAt {
+	return SectionReaderAt(r, 0, n)
+}
+
+type sectionReaderAt struct {
+	r    ReaderAt
+	base int64 // 在r中的起始位置
+	size int64
+	off  int64 // Read 位置
+}
+
+func (s *sectionReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 || s.base < 0 {
+		return 0, ErrNegativeOffset
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	if remain := s.size - off; remain < int64(len(p)) {
+		n, err = s.r.ReadAt(p[:remain], s.base+off)
+		if err == nil {
+			err = io.EOF
+		}
+		return
+	}
+	return s.r.ReadAt(p, s.base+off)
+}
+
+func (s *sectionReaderAt) Read(p []byte) (n int, err error) {
+	n, err = s.ReadAt(p, s.off)
+	s.off += int64(n)
+	return
+}
+
+func (s *sectionReaderAt) Seek(offset int64, whence int) (int64, error) {
+	off, err := resolveSeek(offset, whence, s.off, s.size)
+	if err != nil {
+		return s.off, err
+	}
+	s.off = off
+	return s.off, nil
+}
+
+func (s *sectionReaderAt) Size() int64 {
+	return s.size
+}
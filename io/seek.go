@@ -0,0 +1,31 @@
+package ioutils
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// 计算 Seek 的目标位置，cur 为当前位置
+// 目标位置需在 [0, size] 内，size < 0 表示大小未知，此时不支持 io.SeekEnd 且不限制上限
+func resolveSeek(offset int64, whence int, cur, size int64) (int64, error) {
+	var off int64
+	switch whence {
+	case io.SeekStart:
+		off = offset
+	case io.SeekCurrent:
+		off = cur + offset
+	case io.SeekEnd:
+		if size < 0 {
+			return cur, ErrUnknownSize
+		}
+		off = size + offset
+	default:
+		return cur, errors.Errorf("invalid whence:%d", whence)
+	}
+
+	if off < 0 || (size >= 0 && off > size) {
+		return cur, errors.Errorf("out of range off:%d", off)
+	}
+	return off, nil
+}
@@ -0,0 +1,73 @@
+package ioutils
+
+import (
+	"io"
+	"sync"
+)
+
+// ReaderAtFromSeeker
+// 通过 Seek + Read 为 io.ReadSeeker 提供并发安全的 io.ReaderAt
+// 所有访问r的操作在锁内串行执行
+// @param restore ReadAt 后是否恢复r原来的位置，否则 Read 位置不确定
+// io.ErrUnexpectedEOF 转换为 io.EOF
+func ReaderAtFromSeeker(r io.ReadSeeker, restore bool) *seekerReaderAt {
+	return &seekerReaderAt{r: r, restore: restore}
+}
+
+type seekerReaderAt struct {
+	r       io.ReadSeeker
+	restore bool
+	lock    sync.Mutex
+}
+
+func (s *seekerReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var cur int64
+	if s.restore {
+		if cur, err = s.r.Seek(0, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err = s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err = io.ReadFull(s.r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	if s.restore {
+		if _, serr := s.r.Seek(cur, io.SeekStart); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return n, err
+}
+
+func (s *seekerReaderAt) Read(p []byte) (n int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.r.Read(p)
+}
+
+func (s *seekerReaderAt) Seek(offset int64, whence int) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.r.Seek(offset, whence)
+}
+
+func (s *seekerReaderAt) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+var _ ReadSeekReaderAt = (*seekerReaderAt)(nil)
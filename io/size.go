@@ -2,26 +2,125 @@ package ioutils
 
 import (
 	"io"
+	"io/fs"
+	"net/http"
+	"sync"
 
 	"github.com/pkg/errors"
 )
 
+type StreamSizeOption func(*StreamSizeOptions)
+
+type StreamSizeOptions struct {
+	NoSideEffect bool // 禁止有副作用的方式，如 Seek
+}
+
+// SetNoSideEffect
+// 禁止使用有副作用的方式获取大小
+// 用于可能被并发访问的数据源
+func SetNoSideEffect(b bool) StreamSizeOption {
+	return func(o *StreamSizeOptions) {
+		o.NoSideEffect = b
+	}
+}
+
+// 自定义大小获取方式
+// ok为false时尝试下一种方式
+type StreamSizeResolver func(r any) (size int64, ok bool)
+
+type streamSizeResolver struct {
+	fn         StreamSizeResolver
+	sideEffect bool
+}
+
+var (
+	streamSizeLock      sync.RWMutex
+	streamSizeResolvers []streamSizeResolver
+)
+
+// RegisterStreamSizeResolver
+// 注册自定义大小获取方式，优先于内置方式，按注册顺序尝试
+// @param sideEffect 是否有副作用，设置 SetNoSideEffect 时跳过
+func RegisterStreamSizeResolver(fn StreamSizeResolver, sideEffect bool) {
+	streamSizeLock.Lock()
+	defer streamSizeLock.Unlock()
+	streamSizeResolvers = append(streamSizeResolvers, streamSizeResolver{fn: fn, sideEffect: sideEffect})
+}
+
 // GetStreamSize
+// 依次尝试以下方式获取大小
+// 1. 注册的自定义方式
+// 2. Size() 方法，返回负数表示大小未知，继续尝试其他方式
+// 3. Stat() 方法，仅限普通文件
+// 4. Len() 方法，为可读取部分大小
+// 5. *http.Response、*http.Request 的 ContentLength
+// 6. Seek 到末尾，有副作用
+func GetStreamSize(r any, opts ...StreamSizeOption) (int64, error) {
+	var options StreamSizeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	streamSizeLock.RLock()
+	resolvers := streamSizeResolvers
+	streamSizeLock.RUnlock()
+	for _, resolver := range resolvers {
+		if resolver.sideEffect && options.NoSideEffect {
+			continue
+		}
+		if size, ok := resolver.fn(r); ok {
+			return size, nil
+		}
+	}
+
+	if size, ok := streamSizeByMethod(r); ok && size >= 0 {
+		return size, nil
+	}
+
+	if v, ok := r.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		if info, err := v.Stat(); err == nil && info.Mode().IsRegular() {
+			return info.Size(), nil
+		}
+	}
+
+	if size, err := GetStreamLen(r); err == nil {
+		return size, nil
+	}
+
+	switch v := r.(type) {
+	case *http.Response:
+		if v.ContentLength >= 0 {
+			return v.ContentLength, nil
+		}
+	case *http.Request:
+		if v.ContentLength >= 0 {
+			return v.ContentLength, nil
+		}
+	}
+
+	if s, ok := r.(io.Reader); ok && !options.NoSideEffect {
+		if size, err := StreamSizeBySeeking(s, true); err == nil {
+			return size, nil
+		}
+	}
+	return 0, errors.Errorf("unable to get size of %T", r)
+}
+
 // 使用 Size() 方法获取大小
-func GetStreamSize(r any) (int64, error) {
+func streamSizeByMethod(r any) (int64, bool) {
 	switch v := r.(type) {
 	case interface{ Size() int64 }:
-		return v.Size(), nil
+		return v.Size(), true
 	case interface{ Size() int32 }:
-		return int64(v.Size()), nil
+		return int64(v.Size()), true
 	case interface{ Size() int16 }:
-		return int64(v.Size()), nil
+		return int64(v.Size()), true
 	case interface{ Size() int8 }:
-		return int64(v.Size()), nil
+		return int64(v.Size()), true
 	case interface{ Size() int }:
-		return int64(v.Size()), nil
+		return int64(v.Size()), true
 	}
-	return 0, errors.Errorf("input must be of Size() method")
+	return 0, false
 }
 
 // GetStreamLen
@@ -0,0 +1,93 @@
+package ioutils
+
+import (
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// 稀疏文件中的数据段
+type SparseExtent struct {
+	Off int64
+	R   SizeReaderAt
+}
+
+// 数据段或空洞的范围
+type Range struct {
+	Off  int64
+	Size int64
+}
+
+// SparseReaderAt
+// 由数据段组成的稀疏文件，数据段之间的空洞读取为零值
+// 数据段不能重叠且不能超出 size
+// 关闭时一并关闭实现了 io.Closer 的数据段，CloseError.Index 为数据段在参数中的索引
+func SparseReaderAt(size int64, extents ...SparseExtent) (*sparseReaderAt, error) {
+	s := &sparseReaderAt{closes: make([]io.Closer, len(extents))}
+	for i, e := range extents {
+		if c, ok := e.R.(io.Closer); ok {
+			s.closes[i] = c
+		}
+	}
+
+	extents = append([]SparseExtent(nil), extents...)
+	sort.Slice(extents, func(i, j int) bool { return extents[i].Off < extents[j].Off })
+
+	parts := make([]SizeReaderAt, 0, len(extents)*2+1)
+	var off int64
+	for _, e := range extents {
+		if e.Off < off {
+			return nil, errors.Errorf("extent overlap off:%d", e.Off)
+		}
+		esize := e.R.Size()
+		if esize < 0 {
+			return nil, errors.Wrapf(ErrUnknownSize, "extent off:%d", e.Off)
+		}
+		if esize == 0 {
+			continue
+		}
+		if e.Off+esize > size {
+			return nil, errors.Errorf("extent out of range off:%d size:%d", e.Off, esize)
+		}
+
+		if e.Off > off {
+			s.holes = append(s.holes, Range{off, e.Off - off})
+			parts = append(parts, ZeroReaderAt(e.Off-off))
+		}
+		s.extents = append(s.extents, Range{e.Off, esize})
+		parts = append(parts, e.R)
+		off = e.Off + esize
+	}
+	if off < size {
+		s.holes = append(s.holes, Range{off, size - off})
+		parts = append(parts, ZeroReaderAt(size-off))
+	}
+
+	s.multiReaderAt = newMultiReaderAt(parts)
+	return s, nil
+}
+
+type sparseReaderAt struct {
+	*multiReaderAt
+	extents []Range
+	holes   []Range
+	closes  []io.Closer // 按参数顺序
+}
+
+// Extents 按偏移排序的数据段范围
+func (s *sparseReaderAt) Extents() []Range {
+	return append([]Range(nil), s.extents...)
+}
+
+// Holes 按偏移排序的空洞范围，复制时可跳过
+func (s *sparseReaderAt) Holes() []Range {
+	return append([]Range(nil), s.holes...)
+}
+
+// Close 按参数顺序关闭数据段，不经过 multiReaderAt 以免索引包含空洞
+func (s *sparseReaderAt) Close() error {
+	return NewMultiCloser(s.closes, SkipNilCloser(true)).Close()
+}
+
+var _ SizeReaderAtCloser = (*sparseReaderAt)(nil)
@@ -0,0 +1,69 @@
+package ioutils_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	ioutils "github.com/foxxorcat/library-go/io"
+)
+
+func TestLimitWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := ioutils.LimitWriter(&buf, 10)
+	if n, err := w.Write(make([]byte, 16)); n != 16 || err != nil {
+		t.Errorf("超出部分应视为写入成功 n=%d err=%v", n, err)
+	}
+	if buf.Len() != 10 || w.Remaining() != 0 {
+		t.Errorf("写入大小错误 %d", buf.Len())
+	}
+
+	buf.Reset()
+	w = ioutils.StrictLimitWriter(&buf, 10)
+	if n, err := w.Write(make([]byte, 6)); n != 6 || err != nil || w.Remaining() != 4 {
+		t.Errorf("写入错误 n=%d err=%v", n, err)
+	}
+	n, err := w.Write(make([]byte, 6))
+	var le *ioutils.LimitExceededError
+	if n != 4 || !errors.Is(err, ioutils.ErrLimitExceeded) || !errors.As(err, &le) || le.Accepted != 4 {
+		t.Errorf("超出限制应该返回 ErrLimitExceeded n=%d err=%v", n, err)
+	}
+	if buf.Len() != 10 {
+		t.Errorf("写入大小错误 %d", buf.Len())
+	}
+}
+
+func TestLimitWriterAt(t *testing.T) {
+	file, err := os.CreateTemp("", "iotest-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(file.Name())
+	defer file.Close()
+	file.Write(make([]byte, 32))
+
+	w := ioutils.LimitWriterAt(file, 8, 8)
+	if n, err := w.Write([]byte("abcd")); n != 4 || err != nil {
+		t.Errorf("写入错误 n=%d err=%v", n, err)
+	}
+	if n, err := w.Write([]byte("efghij")); n != 4 || !errors.Is(err, ioutils.ErrLimitExceeded) {
+		t.Errorf("超出限制应该返回 ErrLimitExceeded n=%d err=%v", n, err)
+	}
+	if n, err := w.WriteAt([]byte("x"), 8); n != 0 || !errors.Is(err, ioutils.ErrLimitExceeded) {
+		t.Errorf("超出范围应该返回 ErrLimitExceeded n=%d err=%v", n, err)
+	}
+
+	ow := ioutils.OffsetWriterAt(file, 20)
+	if _, err := ow.WriteAt([]byte("XY"), 2); err != nil {
+		t.Error(err)
+	}
+
+	data, _ := os.ReadFile(file.Name())
+	want := make([]byte, 32)
+	copy(want[8:], "abcdefgh")
+	copy(want[22:], "XY")
+	if !bytes.Equal(data, want) {
+		t.Errorf("写入内容错误 %q", data)
+	}
+}
@@ -1,14 +1,95 @@
 package ioutils
 
+import "io"
+
 // 无限读取零值
 var Zero zeroReader
 
+// 共享的零值页，只读
+var zeroPage [32 * 1024]byte
+
 type zeroReader struct{}
 
 func (zeroReader) Read(p []byte) (n int, err error) {
-	n = len(p)
-	for i := 0; i < len(p); i++ {
-		p[i] = 0
+	clear(p)
+	return len(p), nil
+}
+
+func (zeroReader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+	return Zero.Read(p)
+}
+
+// ZeroReaderAt
+// 读取size字节零值
+// 可用于填充稀疏文件的空洞
+func ZeroReaderAt(size int64) SizeReadSeekReaderAt {
+	if size < 0 {
+		size = 0
+	}
+	return &zeroReaderAt{size: size}
+}
+
+type zeroReaderAt struct {
+	size int64
+	off  int64 // Read 位置
+}
+
+func (z *zeroReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+	if off >= z.size {
+		return 0, io.EOF
+	}
+
+	if remain := z.size - off; remain < int64(len(p)) {
+		p, err = p[:remain], io.EOF
+	}
+	clear(p)
+	return len(p), err
+}
+
+func (z *zeroReaderAt) Read(p []byte) (n int, err error) {
+	n, err = z.ReadAt(p, z.off)
+	z.off += int64(n)
+	return
+}
+
+// WriteTo 从共享零值页写入剩余数据
+func (z *zeroReaderAt) WriteTo(w io.Writer) (n int64, err error) {
+	for z.off < z.size {
+		page := zeroPage[:]
+		if remain := z.size - z.off; remain < int64(len(page)) {
+			page = page[:remain]
+		}
+
+		wn, err := w.Write(page)
+		n += int64(wn)
+		z.off += int64(wn)
+		if err != nil {
+			return n, err
+		}
+		if wn < len(page) {
+			return n, io.ErrShortWrite
+		}
 	}
 	return n, nil
 }
+
+func (z *zeroReaderAt) Seek(offset int64, whence int) (int64, error) {
+	off, err := resolveSeek(offset, whence, z.off, z.size)
+	if err != nil {
+		return z.off, err
+	}
+	z.off = off
+	return z.off, nil
+}
+
+func (z *zeroReaderAt) Size() int64 {
+	return z.size
+}
+
+var _ io.WriterTo = (*zeroReaderAt)(nil)